@@ -6,7 +6,12 @@ type CyclicRedundancyCheck32 struct {
 }
 
 func (crc32 *CyclicRedundancyCheck32) Checksum(data []byte) uint32 {
-	var crc uint32 = 0xffffffff
+	return crc32.Update(0, data)
+}
+
+// Update returns the checksum of the data appended to the data whose checksum is crc.
+func (crc32 *CyclicRedundancyCheck32) Update(crc uint32, data []byte) uint32 {
+	crc = ^crc
 
 	for _, b := range data {
 		crc = (crc >> 8) ^ crc32.table[(crc^uint32(b))&0xff]
@@ -15,6 +20,12 @@ func (crc32 *CyclicRedundancyCheck32) Checksum(data []byte) uint32 {
 	return ^crc
 }
 
+// UpdateByte feeds a single byte to a raw CRC-32 register, without the
+// inversions Checksum and Update apply.
+func (crc32 *CyclicRedundancyCheck32) UpdateByte(crc uint32, b byte) uint32 {
+	return (crc >> 8) ^ crc32.table[(crc^uint32(b))&0xff]
+}
+
 func NewCRC32() *CyclicRedundancyCheck32 {
 	var crc32 = &CyclicRedundancyCheck32{0xedb88320, [256]uint32{}}
 
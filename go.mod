@@ -2,4 +2,11 @@ module go-zipfile
 
 go 1.25
 
-require golang.org/x/sys v0.39.0
+require (
+	github.com/dsnet/compress v0.0.1
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/sys v0.39.0
+	golang.org/x/term v0.38.0
+	golang.org/x/text v0.32.0
+)
@@ -1,16 +1,179 @@
 package main
 
 import (
+	"compress/flate"
 	"flag"
 	"fmt"
 	"go-zipfile/zipfile"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"golang.org/x/term"
 )
 
+func parseMethod(name string) (uint16, bool) {
+	if method, err := strconv.ParseUint(name, 10, 16); err == nil {
+		return uint16(method), true
+	}
+	for method, methodName := range zipfile.MapOfCompressionMethod {
+		if strings.EqualFold(name, methodName) {
+			return method, true
+		}
+	}
+	return 0, false
+}
+
+// readPassword asks for a password on the terminal without echoing it,
+// twice when it is about to encrypt something.
+func readPassword(verify bool) string {
+	_, _ = fmt.Fprint(os.Stderr, "password: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		panic(err)
+	}
+	if verify {
+		_, _ = fmt.Fprint(os.Stderr, "verify password: ")
+		again, err := term.ReadPassword(int(os.Stdin.Fd()))
+		_, _ = fmt.Fprintln(os.Stderr)
+		if err != nil {
+			panic(err)
+		}
+		if string(again) != string(password) {
+			_, _ = fmt.Fprintln(os.Stderr, "passwords do not match")
+			os.Exit(1)
+		}
+	}
+	return string(password)
+}
+
+func extract(arguments []string) {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	dir := flags.String("o", ".", "extract files into `directory`")
+	overwrite := flags.Bool("f", false, "overwrite existing files")
+	restoreTimes := flags.Bool("t", false, "restore modification times, preferring NTFS times when present")
+	restorePermissions := flags.Bool("p", false, "restore POSIX permissions")
+	restoreSpecialBits := flags.Bool("K", false, "with -p, also restore the set-user-ID, set-group-ID and sticky bits")
+	restoreOwnership := flags.Bool("O", false, "restore owner and group (needs privileges)")
+	password := flags.String("P", "", "decrypt entries with `password`")
+	prompt := flags.Bool("e", false, "prompt for the password of encrypted entries")
+	codePageName := flags.String("C", "", "decode names without the UTF-8 flag with `codepage` (cp437, cp866, shift_jis, gbk or utf-8) instead of cp437")
+	_ = flags.Parse(arguments)
+
+	args := flags.Args()
+	if len(args) != 1 {
+		_, _ = fmt.Fprintln(flags.Output(), "extract needs exactly one archive")
+		flags.Usage()
+		os.Exit(2)
+	}
+	codePage, ok := zipfile.MapOfCodePage[strings.ToLower(*codePageName)]
+	if !ok && *codePageName != "" {
+		_, _ = fmt.Fprintln(flags.Output(), "unknown code page", *codePageName)
+		os.Exit(2)
+	}
+
+	zip, err := zipfile.Open(args[0])
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = zip.Close() }()
+
+	if *prompt {
+		*password = readPassword(false)
+	}
+	zip.SetPassword(*password)
+	zip.SetCodePage(codePage)
+
+	if err = zip.ExtractAll(*dir, zipfile.ExtractOptions{
+		Overwrite:          *overwrite,
+		RestoreModTime:     *restoreTimes,
+		RestorePermissions: *restorePermissions,
+		RestoreSpecialBits: *restoreSpecialBits,
+		RestoreOwnership:   *restoreOwnership,
+		RestoreNTFSTimes:   *restoreTimes,
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// addTree adds path and everything below it. Following links, the directories
+// they point to are walked too, unless that would never end: when they hold the
+// link itself or a directory walked already.
+func addTree(zip *zipfile.Zip, root string, follow bool, walking []string) error {
+	// a link given as root is only walked once followed, as root + "/"
+	if stat, err := os.Lstat(root); err == nil && stat.Mode()&os.ModeSymlink == 0 {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			walking = append(walking, resolved)
+		}
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == "." {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 && follow {
+			if info, err = os.Stat(path); err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, "skipping", path+":", err)
+				return nil
+			}
+			if info.IsDir() {
+				if linksBack(path, walking) {
+					_, _ = fmt.Fprintln(os.Stderr, "skipping", path+": symbolic link cycle")
+					return nil
+				}
+				// Walk does not descend into linked directories by itself
+				return addTree(zip, path+string(filepath.Separator), follow, walking)
+			}
+		}
+		if info.IsDir() && !strings.HasSuffix(path, string(filepath.Separator)) {
+			path = path + string(filepath.Separator)
+		}
+		_, _ = fmt.Fprintln(os.Stderr, path)
+		return zip.Add(path)
+	})
+}
+
+// linksBack tells whether the directory a link points to holds the link or one
+// of the directories being walked.
+func linksBack(link string, walking []string) bool {
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		return true
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(link))
+	if err != nil {
+		return true
+	}
+	for _, dir := range walking {
+		if zipfile.IsWithin(target, dir) {
+			return true
+		}
+	}
+	return zipfile.IsWithin(target, parent)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "extract" {
+		extract(os.Args[2:])
+		return
+	}
+
 	useDeflate := flag.Bool("d", false, "compress archive with deflate algorithm")
+	methodName := flag.String("m", "", "compress archive with the registered `method`, given by name or number")
+	level := flag.Int("l", flate.DefaultCompression, "compression `level`, whose meaning depends on the method")
+	encoderThreads := flag.Int("T", 1, "encode each entry with `n` threads, for the methods that can, 0 for all processors")
+	password := flag.String("P", "", "encrypt entries with `password`, by the method chosen with -A (traditional PKWARE encryption by default)")
+	prompt := flag.Bool("e", false, "prompt for the password to encrypt entries with")
+	aesBits := flag.Int("A", 0, "encrypt with WinZip AES using a key of `bits` (128, 192 or 256) instead")
+	policy := flag.Bool("S", false, "store entries that compress poorly, judged by their extension, their first bytes and a sample")
+	workers := flag.Int("j", 1, "compress `n` entries or chunks of entries at once, 0 for all processors")
+	memory := flag.Int64("M", zipfile.DefaultMemoryBudget>>20, "hold at most `MiB` of entry data in memory when compressing in parallel")
+	symlinks := flag.String("L", "store", "add symbolic links as links (store), as the files they point to (follow) or not at all (skip)")
 	flag.Parse()
 
 	args := flag.Args()
@@ -25,20 +188,64 @@ func main() {
 	if *useDeflate {
 		zip.SetCompressionMethod(zipfile.CompressionMethodDeflated)
 	}
+	if *methodName != "" {
+		method, ok := parseMethod(*methodName)
+		if !ok {
+			_, _ = fmt.Fprintln(flag.CommandLine.Output(), "unknown compression method", *methodName)
+			flag.Usage()
+			os.Exit(2)
+		}
+		zip.SetCompressionMethod(method)
+	}
+	zip.SetCompressionLevel(*level)
+	zip.SetEncoderConcurrency(*encoderThreads)
+	if *policy {
+		zip.SetCompressionPolicy(zipfile.NewCompressionPolicy())
+	}
+	switch *symlinks {
+	case "store":
+		zip.SetSymlinkPolicy(zipfile.SymlinkStore)
+	case "follow":
+		zip.SetSymlinkPolicy(zipfile.SymlinkFollow)
+	case "skip":
+		zip.SetSymlinkPolicy(zipfile.SymlinkSkip)
+	default:
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "unknown symbolic link policy", *symlinks)
+		flag.Usage()
+		os.Exit(2)
+	}
+	zip.SetWorkers(*workers)
+	zip.SetMemoryBudget(*memory << 20)
+	if *prompt {
+		*password = readPassword(true)
+	}
+	zip.SetPassword(*password)
+
+	switch *aesBits {
+	case 0:
+	case 128:
+		zip.SetEncryption(zipfile.EncryptionAES128)
+	case 192:
+		zip.SetEncryption(zipfile.EncryptionAES192)
+	case 256:
+		zip.SetEncryption(zipfile.EncryptionAES256)
+	default:
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "AES keys have 128, 192 or 256 bits")
+		flag.Usage()
+		os.Exit(2)
+	}
 
 	for _, arg := range args[1:] {
-		if err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
-			if path == "." {
-				return nil
-			}
-			if info.IsDir() && !strings.HasSuffix(path, "\\") {
-				path = path + "\\"
-			}
-			fmt.Println(path)
-			return zip.Add(path)
-		}); err != nil {
+		if err := addTree(zip, arg, *symlinks == "follow", nil); err != nil {
+			panic(err)
+		}
+	}
+
+	if out == "-" {
+		if _, err := zip.WriteTo(os.Stdout); err != nil {
 			panic(err)
 		}
+		return
 	}
 
 	f, err := os.Create(out)
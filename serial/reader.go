@@ -16,6 +16,11 @@ type options struct {
 	Size      string `tag:"size"`
 	Condition string `tag:"condition"`
 	Prefix    string `tag:"prefix"`
+	Stream    string `tag:"stream"`
+}
+
+func (o *options) IsStream() bool {
+	return o.Stream == "true"
 }
 
 func (o *options) CheckPrefix(r io.ReadSeeker) bool {
@@ -119,6 +124,10 @@ func parseTag(tag string) (opts options) {
 	tags := strings.Split(tag, ",")
 	for _, t := range tags {
 		eq := strings.SplitN(t, "=", 2)
+		if len(eq) == 1 {
+			// bare options such as "stream" are flags
+			eq = append(eq, "true")
+		}
 		for i := range typ.NumField() {
 			if typ.Field(i).Tag.Get("tag") == eq[0] {
 				val.Field(i).SetString(eq[1])
@@ -137,6 +146,12 @@ func (u *unmarshaler) unmarshal(v, parent any, tag string) (err error) {
 		return fmt.Errorf("cannot unmarshal nil pointer")
 	}
 
+	if streamDeserializable, ok := v.(IStreamDeserializable); ok {
+		if opts := parseTag(tag); opts.IsStream() {
+			return streamDeserializable.UnmarshalStream(u.r, int64(opts.GetLength(parent)))
+		}
+	}
+
 	if deserializable, ok := v.(IDeserializable); ok {
 		return deserializable.Unmarshal(u.r)
 	}
@@ -163,6 +178,10 @@ func (u *unmarshaler) unmarshal(v, parent any, tag string) (err error) {
 			if !opts.GetConditionalResult(parent) {
 				return
 			}
+			if len(opts.Prefix) > 0 && !opts.CheckPrefix(u.r) {
+				// optional records are only present when their signature is
+				return
+			}
 			value.Set(reflect.New(varType.Elem()))
 		}
 		return u.unmarshal(value.Interface(), v, "")
@@ -170,6 +189,11 @@ func (u *unmarshaler) unmarshal(v, parent any, tag string) (err error) {
 		opts := parseTag(tag)
 		length := opts.GetLength(parent)
 		value.Set(reflect.MakeSlice(value.Type(), length, length))
+		if length > 0 && varType.Elem().Kind() == reflect.Uint8 {
+			// if value is bytes buffer, read it from file directly
+			_, err = io.ReadFull(u.r, value.Bytes())
+			return
+		}
 		if value.Len() == 0 {
 			for {
 				if !opts.CheckPrefix(u.r) {
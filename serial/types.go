@@ -3,13 +3,19 @@ package serial
 import "io"
 
 type ISerializable interface {
-	Marshal(io.WriteSeeker) error
+	Marshal(io.Writer) error
 }
 
 type IDeserializable interface {
 	Unmarshal(io.ReadSeeker) error
 }
 
+// IStreamDeserializable is used for fields tagged with "stream", which receive
+// the reader together with the length given by their "len" option.
+type IStreamDeserializable interface {
+	UnmarshalStream(io.ReadSeeker, int64) error
+}
+
 type ISizeOf interface {
 	SizeOf() uint32
 }
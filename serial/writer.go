@@ -30,7 +30,7 @@ func sizeof(v any) uint32 {
 		if value.IsNil() {
 			return 0
 		}
-		return sizeof(value.Elem())
+		return sizeof(value.Elem().Interface())
 	case reflect.Array, reflect.Slice:
 		size := uint32(0)
 		for j := 0; j < value.Len(); j++ {
@@ -62,7 +62,7 @@ func sizeof(v any) uint32 {
 }
 
 type marshaller struct {
-	w io.WriteSeeker
+	w io.Writer
 }
 
 func (m *marshaller) marshal(v any) (err error) {
@@ -88,7 +88,7 @@ func (m *marshaller) marshal(v any) (err error) {
 		if value.IsNil() {
 			return
 		}
-		return m.marshal(value.Elem())
+		return m.marshal(value.Elem().Interface())
 	case reflect.Slice:
 		if varType.Elem().Kind() == reflect.Uint8 {
 			// if value is bytes buffer, write it into file directly
@@ -113,7 +113,7 @@ func (m *marshaller) marshal(v any) (err error) {
 	return
 }
 
-func Marshal(writer io.WriteSeeker, v any) error {
+func Marshal(writer io.Writer, v any) error {
 	m := marshaller{writer}
 	return m.marshal(v)
 }
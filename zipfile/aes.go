@@ -0,0 +1,72 @@
+package zipfile
+
+import (
+	"errors"
+	"go-zipfile/zipfile/extrafield"
+	"go-zipfile/zipfile/winzipaes"
+)
+
+const (
+	EncryptionZipCrypto uint8 = iota
+	EncryptionAES128
+	EncryptionAES192
+	EncryptionAES256
+)
+
+// WinZip recommends AE-2 below this size, where the CRC-32 could give the content away
+const aesVersion2Threshold = 20
+
+var errEncryption = errors.New("zip: unknown encryption method")
+
+// aesStrengths maps the AES encryption methods to the strength of the AES record.
+var aesStrengths = map[uint8]uint8{
+	EncryptionAES128: winzipaes.Strength128,
+	EncryptionAES192: winzipaes.Strength192,
+	EncryptionAES256: winzipaes.Strength256,
+}
+
+// encryptAES marks the header for WinZip AES, which moves the compression
+// method into an AES record of the extra field.
+func (h *FileHeader) encryptAES(strength uint8, version uint16) {
+	record, _ := (&extrafield.AESExtraField{
+		Version:           version,
+		VendorID:          extrafield.AESVendorID,
+		Strength:          strength,
+		CompressionMethod: h.CompressionMethod,
+	}).MarshalBinary()
+
+	h.ExtraField = append(extrafield.Remove(h.ExtraField, extrafield.AESTagType), record...)
+	h.CompressionMethod = CompressionMethodAEx
+	h.Flags |= EncryptedFlag
+}
+
+// encrypt sets up the header for the given encryption method. The size of the
+// data, when known, chooses between AE-1 and AE-2.
+func (h *FileHeader) encrypt(method uint8, size uint64) error {
+	switch method {
+	case EncryptionZipCrypto:
+		h.Flags |= EncryptedFlag
+	case EncryptionAES128, EncryptionAES192, EncryptionAES256:
+		version := extrafield.AESVersion1
+		if size < aesVersion2Threshold {
+			version = extrafield.AESVersion2
+		}
+		h.encryptAES(aesStrengths[method], version)
+	default:
+		return errEncryption
+	}
+	return nil
+}
+
+// readAESExtraField returns the AES record of an entry whose method is AE-x.
+func readAESExtraField(extra []byte) (field extrafield.AESExtraField, err error) {
+	if !findExtraField(extra, &field) || field.VendorID != extrafield.AESVendorID {
+		return field, ErrFormat
+	}
+	switch field.Strength {
+	case winzipaes.Strength128, winzipaes.Strength192, winzipaes.Strength256:
+	default:
+		return field, ErrAlgorithm
+	}
+	return
+}
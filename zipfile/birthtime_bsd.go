@@ -0,0 +1,16 @@
+//go:build darwin || ios || freebsd || netbsd
+
+package zipfile
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func birthTime(_ string, st *unix.Stat_t, _ bool) time.Time {
+	if st.Btim.Sec == 0 && st.Btim.Nsec == 0 {
+		return time.Time{}
+	}
+	return timespecToTime(st.Btim)
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package zipfile
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// birthTime asks statx for the birth time, which only some file systems keep.
+func birthTime(path string, _ *unix.Stat_t, follow bool) time.Time {
+	flags := unix.AT_SYMLINK_NOFOLLOW
+	if follow {
+		flags = 0
+	}
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, flags, unix.STATX_BTIME, &stx); err != nil || stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec))
+}
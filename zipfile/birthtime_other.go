@@ -0,0 +1,15 @@
+//go:build unix && !linux && !darwin && !ios && !freebsd && !netbsd
+
+package zipfile
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// birthTime returns the zero time, the system keeps no birth time. The status
+// change time is no substitute, since it changes with the metadata.
+func birthTime(string, *unix.Stat_t, bool) time.Time {
+	return time.Time{}
+}
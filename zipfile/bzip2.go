@@ -0,0 +1,24 @@
+package zipfile
+
+import (
+	"compress/bzip2"
+	"io"
+
+	dsnetbzip2 "github.com/dsnet/compress/bzip2"
+)
+
+func init() {
+	RegisterCompressor(CompressionMethodBZIP2, newBzip2Writer)
+	RegisterDecompressor(CompressionMethodBZIP2, func(r io.Reader) io.ReadCloser {
+		return io.NopCloser(bzip2.NewReader(r))
+	})
+}
+
+// newBzip2Writer takes the levels of the bzip2 tool, 1 to 9, which set the
+// block size in units of 100 kB. Other levels select 6.
+func newBzip2Writer(w io.Writer, level int) (io.WriteCloser, error) {
+	if level < dsnetbzip2.BestSpeed || level > dsnetbzip2.BestCompression {
+		level = dsnetbzip2.DefaultCompression
+	}
+	return dsnetbzip2.NewWriter(w, &dsnetbzip2.WriterConfig{Level: level})
+}
@@ -0,0 +1,89 @@
+package zipfile
+
+import (
+	"go-zipfile/zipfile/extrafield"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// MapOfCodePage holds the code pages of names and comments written without
+// the UTF-8 flag, by name. Localized Windows versions write them in their OEM
+// code page rather than in CP437, the one APPNOTE specifies, and some Unix
+// tools in UTF-8 without setting the flag.
+var MapOfCodePage = map[string]encoding.Encoding{
+	"cp437":     charmap.CodePage437,
+	"cp866":     charmap.CodePage866,
+	"shift_jis": japanese.ShiftJIS,
+	"gbk":       simplifiedchinese.GBK,
+	"utf-8":     encoding.Nop,
+}
+
+// SetCodePage sets the code page of the names and comments of the entries
+// without the UTF-8 flag nor Info-ZIP Unicode records, CP437 if nil, and
+// decodes them again. It applies to the archive comment too, which has no
+// flag to tell it is UTF-8.
+func (zr *Reader) SetCodePage(codePage encoding.Encoding) {
+	zr.codePage = codePage
+	zr.Comment = zr.decode(zr.rawComment, 0)
+	for _, e := range zr.Entries {
+		e.decodeText()
+	}
+}
+
+// decodeText sets the name and the comment of the entry from their bytes in
+// the central header.
+func (e *Entry) decodeText() {
+	e.Name = e.reader.decode(e.rawName, e.Flags)
+	e.Comment = e.reader.decode(e.rawComment, e.Flags)
+	if e.Flags&LanguageEncodingFlag != 0 {
+		return
+	}
+
+	// the records only apply while the header still holds the text they were written for
+	var path extrafield.UnicodePathExtraField
+	if findExtraField(e.ExtraField, &path) && path.NameCRC32 == crc32.Checksum(e.rawName) && utf8.ValidString(path.UnicodeName) {
+		e.Name = path.UnicodeName
+	}
+	var comment extrafield.UnicodeCommentExtraField
+	if findExtraField(e.ExtraField, &comment) && comment.CommentCRC32 == crc32.Checksum(e.rawComment) && utf8.ValidString(comment.UnicodeComment) {
+		e.Comment = comment.UnicodeComment
+	}
+}
+
+func (zr *Reader) decode(text []byte, flags uint16) string {
+	if flags&LanguageEncodingFlag != 0 || isASCII(text) {
+		return string(text)
+	}
+	codePage := zr.codePage
+	if codePage == nil {
+		codePage = charmap.CodePage437
+	}
+	decoded, err := codePage.NewDecoder().Bytes(text)
+	if err != nil {
+		return string(text)
+	}
+	return string(decoded)
+}
+
+// encodingFlags returns LanguageEncodingFlag if the name or the comment are
+// UTF-8 beyond ASCII, which readers would otherwise take for CP437.
+func (h *FileHeader) encodingFlags() uint16 {
+	name, comment := []byte(h.Name), []byte(h.Comment)
+	if isASCII(name) && isASCII(comment) || !utf8.Valid(name) || !utf8.Valid(comment) {
+		return 0
+	}
+	return LanguageEncodingFlag
+}
+
+func isASCII(text []byte) bool {
+	for _, b := range text {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
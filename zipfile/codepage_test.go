@@ -0,0 +1,31 @@
+package zipfile
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestArchiveCommentCodePage(t *testing.T) {
+	// "Привет" in CP866
+	comment := string([]byte{0x8f, 0xe0, 0xa8, 0xa2, 0xa5, 0xe2})
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetComment(comment)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zr.Comment != "Åα¿óÑΓ" {
+		t.Errorf("comment = %q, want it decoded from CP437", zr.Comment)
+	}
+	zr.SetCodePage(charmap.CodePage866)
+	if zr.Comment != "Привет" {
+		t.Errorf("comment = %q, want it decoded from CP866", zr.Comment)
+	}
+}
@@ -1,10 +1,13 @@
 package zipfile
 
 var (
-	LocalFileHeaderSignature       Signature = [4]byte{0x50, 0x4b, 0x03, 0x04}
-	CentralFileHeaderSignature               = [4]byte{0x50, 0x4b, 0x01, 0x02}
-	EndOfCentralDirectorySignature           = [4]byte{0x50, 0x4b, 0x05, 0x06}
-	DigitalHeaderSignature                   = [4]byte{0x50, 0x4b, 0x05, 0x05}
+	LocalFileHeaderSignature                   Signature = [4]byte{0x50, 0x4b, 0x03, 0x04}
+	CentralFileHeaderSignature                           = [4]byte{0x50, 0x4b, 0x01, 0x02}
+	EndOfCentralDirectorySignature                       = [4]byte{0x50, 0x4b, 0x05, 0x06}
+	DigitalHeaderSignature                               = [4]byte{0x50, 0x4b, 0x05, 0x05}
+	DataDescriptorSignature                              = [4]byte{0x50, 0x4b, 0x07, 0x08}
+	Zip64EndOfCentralDirectorySignature                  = [4]byte{0x50, 0x4b, 0x06, 0x06}
+	Zip64EndOfCentralDirectoryLocatorSignature           = [4]byte{0x50, 0x4b, 0x06, 0x07}
 )
 
 const (
@@ -54,6 +57,7 @@ var MapOfVersionMadeBy = map[uint8]string{
 }
 
 const DefaultVersion uint16 = 10
+const Zip64Version uint16 = 45
 const LatestVersion uint16 = 63
 
 var MinimumFeatureVersions = map[uint8][]string{
@@ -133,3 +137,30 @@ const (
 	CompressionMethodPPMd
 	CompressionMethodAEx
 )
+
+var MapOfCompressionMethod = map[uint16]string{
+	CompressionMethodStored:                        "stored",
+	CompressionMethodShrunk:                        "shrunk",
+	CompressionMethodReducedWithCompressionFactor1: "reduced (factor 1)",
+	CompressionMethodReducedWithCompressionFactor2: "reduced (factor 2)",
+	CompressionMethodReducedWithCompressionFactor3: "reduced (factor 3)",
+	CompressionMethodReducedWithCompressionFactor4: "reduced (factor 4)",
+	CompressionMethodImploded:                      "imploded",
+	CompressionMethodTokenized:                     "tokenized",
+	CompressionMethodDeflated:                      "deflate",
+	CompressionMethodDeflate64:                     "deflate64",
+	CompressionMethodPKWARE_DCL_Imploded:           "PKWARE DCL imploded",
+	CompressionMethodBZIP2:                         "bzip2",
+	CompressionMethodLZMA:                          "lzma",
+	CompressionMethodIBM_zOS_CMPSC:                 "IBM z/OS CMPSC",
+	CompressionMethodIBM_TERSE:                     "IBM TERSE",
+	CompressionMethodIBM_LZ77_z_Architecture:       "IBM LZ77 z/Architecture",
+	CompressionMethodDeprecatedZSTD:                "zstd (deprecated)",
+	CompressionMethodZSTD:                          "zstd",
+	CompressionMethodMP3:                           "mp3",
+	CompressionMethodXZ:                            "xz",
+	CompressionMethodJPEG:                          "jpeg",
+	CompressionMethodWavPack:                       "wavpack",
+	CompressionMethodPPMd:                          "ppmd",
+	CompressionMethodAEx:                           "AE-x encryption",
+}
@@ -0,0 +1,7 @@
+package zipfile
+
+import "go-zipfile/zipfile/deflate64"
+
+func init() {
+	RegisterDecompressor(CompressionMethodDeflate64, deflate64.NewReader)
+}
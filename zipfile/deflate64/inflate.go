@@ -0,0 +1,406 @@
+// Package deflate64 implements a decompressor for Deflate64, the "enhanced
+// deflate" of PKWARE. It differs from deflate by its 64 KiB window, two more
+// distance codes and a length code 285 taking 16 extra bits.
+package deflate64
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+const (
+	windowSize = 1 << 16
+	windowMask = windowSize - 1
+	maxBits    = 15
+)
+
+var ErrCorrupt = errors.New("zip: corrupt deflate64 data")
+
+var (
+	lengthBase = [29]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31,
+		35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 3,
+	}
+	lengthExtra = [29]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2,
+		3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 16,
+	}
+	distanceBase = [32]uint32{
+		1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193,
+		257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577, 32769, 49153,
+	}
+	distanceExtra = [32]uint8{
+		0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6,
+		7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13, 14, 14,
+	}
+	// order of the code length code lengths in a dynamic block header
+	codeLengthOrder = [19]uint8{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+)
+
+// huffman decodes canonical Huffman codes with a table indexed by the next
+// bits of the input, least significant first. Each entry holds the symbol
+// shifted left by 4 and the length of its code, 0 for unused codes.
+type huffman struct {
+	table []uint16
+	bits  uint
+}
+
+func (h *huffman) init(lengths []uint8) error {
+	var count [maxBits + 1]int
+	bits := uint(0)
+	for _, length := range lengths {
+		count[length]++
+		bits = max(bits, uint(length))
+	}
+	count[0] = 0
+
+	// the code lengths must not describe more codes than fit
+	left := 1
+	for length := 1; length <= maxBits; length++ {
+		left = left<<1 - count[length]
+		if left < 0 {
+			return ErrCorrupt
+		}
+	}
+
+	var next [maxBits + 1]int
+	code := 0
+	for length := 1; length <= maxBits; length++ {
+		code = (code + count[length-1]) << 1
+		next[length] = code
+	}
+
+	if bits == 0 {
+		bits = 1
+	}
+	h.bits = bits
+	if cap(h.table) < 1<<bits {
+		h.table = make([]uint16, 1<<bits)
+	} else {
+		h.table = h.table[:1<<bits]
+		clear(h.table)
+	}
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		code := next[length]
+		next[length]++
+
+		reversed := 0
+		for i := uint8(0); i < length; i++ {
+			reversed = reversed<<1 | code>>i&1
+		}
+		for i := reversed; i < len(h.table); i += 1 << length {
+			h.table[i] = uint16(symbol)<<4 | uint16(length)
+		}
+	}
+	return nil
+}
+
+type reader struct {
+	r     *bufio.Reader
+	bits  uint64
+	nbits uint
+	eof   bool
+
+	window   [windowSize]byte
+	position int
+
+	final    bool
+	inBlock  bool
+	stored   int
+	literal  huffman
+	distance huffman
+
+	copyLength   int
+	copyDistance int
+
+	err error
+}
+
+// NewReader returns a reader decompressing the Deflate64 stream of r. It may
+// read past the end of the stream.
+func NewReader(r io.Reader) io.ReadCloser {
+	return &reader{r: bufio.NewReader(r)}
+}
+
+func (f *reader) Close() error {
+	if f.err == nil || f.err == io.EOF {
+		f.err = errors.New("zip: read from closed deflate64 reader")
+	}
+	return nil
+}
+
+// refill makes at least n bits available, or as many as the input has left.
+func (f *reader) refill(n uint) error {
+	for f.nbits < n && !f.eof {
+		b, err := f.r.ReadByte()
+		if err == io.EOF {
+			f.eof = true
+			break
+		} else if err != nil {
+			return err
+		}
+		f.bits |= uint64(b) << f.nbits
+		f.nbits += 8
+	}
+	return nil
+}
+
+func (f *reader) readBits(n uint) (uint32, error) {
+	if err := f.refill(n); err != nil {
+		return 0, err
+	}
+	if f.nbits < n {
+		return 0, io.ErrUnexpectedEOF
+	}
+	value := uint32(f.bits & (1<<n - 1))
+	f.bits >>= n
+	f.nbits -= n
+	return value, nil
+}
+
+func (f *reader) decode(h *huffman) (int, error) {
+	if err := f.refill(h.bits); err != nil {
+		return 0, err
+	}
+	entry := h.table[f.bits&(1<<h.bits-1)]
+	length := uint(entry & 15)
+	if length == 0 {
+		return 0, ErrCorrupt
+	}
+	if length > f.nbits {
+		return 0, io.ErrUnexpectedEOF
+	}
+	f.bits >>= length
+	f.nbits -= length
+	return int(entry >> 4), nil
+}
+
+func (f *reader) output(p []byte, b byte) {
+	p[0] = b
+	f.window[f.position&windowMask] = b
+	f.position++
+}
+
+func (f *reader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if f.err != nil {
+			break
+		}
+
+		switch {
+		case f.copyLength > 0:
+			f.output(p[n:], f.window[(f.position-f.copyDistance)&windowMask])
+			f.copyLength--
+			n++
+		case f.stored > 0:
+			var b uint32
+			if b, f.err = f.readBits(8); f.err == nil {
+				f.output(p[n:], byte(b))
+				f.stored--
+				n++
+			}
+		case !f.inBlock:
+			if f.final {
+				f.err = io.EOF
+			} else {
+				f.err = f.readBlockHeader()
+			}
+		default:
+			f.err = f.readSymbol(p[n:], &n)
+		}
+	}
+
+	// errors are reported once the bytes decoded before them are consumed
+	if n > 0 {
+		return n, nil
+	}
+	return 0, f.err
+}
+
+// readSymbol decodes one literal, or one match whose bytes are then copied by Read.
+func (f *reader) readSymbol(p []byte, n *int) error {
+	symbol, err := f.decode(&f.literal)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case symbol < 256:
+		f.output(p, byte(symbol))
+		*n++
+		return nil
+	case symbol == 256:
+		f.inBlock = false
+		return nil
+	case symbol > 285:
+		return ErrCorrupt
+	}
+
+	symbol -= 257
+	extra, err := f.readBits(uint(lengthExtra[symbol]))
+	if err != nil {
+		return err
+	}
+	length := lengthBase[symbol] + extra
+
+	if symbol, err = f.decode(&f.distance); err != nil {
+		return err
+	}
+	if extra, err = f.readBits(uint(distanceExtra[symbol])); err != nil {
+		return err
+	}
+	distance := distanceBase[symbol] + extra
+	if int(distance) > f.position || distance > windowSize {
+		return ErrCorrupt
+	}
+
+	f.copyLength = int(length)
+	f.copyDistance = int(distance)
+	return nil
+}
+
+func (f *reader) readBlockHeader() error {
+	header, err := f.readBits(3)
+	if err != nil {
+		return err
+	}
+	f.final = header&1 == 1
+	f.inBlock = true
+
+	switch header >> 1 {
+	case 0:
+		return f.readStoredHeader()
+	case 1:
+		return f.fixedTables()
+	case 2:
+		return f.dynamicTables()
+	default:
+		return ErrCorrupt
+	}
+}
+
+func (f *reader) readStoredHeader() error {
+	// stored blocks start on a byte boundary
+	f.bits >>= f.nbits % 8
+	f.nbits -= f.nbits % 8
+
+	length, err := f.readBits(16)
+	if err != nil {
+		return err
+	}
+	complement, err := f.readBits(16)
+	if err != nil {
+		return err
+	}
+	if length != ^complement&0xffff {
+		return ErrCorrupt
+	}
+
+	// Read copies the stored bytes before looking for the next block
+	f.stored = int(length)
+	f.inBlock = false
+	return nil
+}
+
+func (f *reader) fixedTables() error {
+	var lengths [288 + 32]uint8
+	for i := range 288 {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	for i := 288; i < len(lengths); i++ {
+		lengths[i] = 5
+	}
+
+	if err := f.literal.init(lengths[:288]); err != nil {
+		return err
+	}
+	return f.distance.init(lengths[288:])
+}
+
+func (f *reader) dynamicTables() error {
+	counts, err := f.readBits(14)
+	if err != nil {
+		return err
+	}
+	literals := int(counts&31) + 257
+	distances := int(counts>>5&31) + 1
+	codeLengths := int(counts>>10) + 4
+	if literals > 286 {
+		return ErrCorrupt
+	}
+
+	var lengths [19]uint8
+	for i := range codeLengths {
+		length, err := f.readBits(3)
+		if err != nil {
+			return err
+		}
+		lengths[codeLengthOrder[i]] = uint8(length)
+	}
+	var codeLength huffman
+	if err = codeLength.init(lengths[:]); err != nil {
+		return err
+	}
+
+	all := make([]uint8, literals+distances)
+	for i := 0; i < len(all); {
+		symbol, err := f.decode(&codeLength)
+		if err != nil {
+			return err
+		}
+
+		var value uint8
+		var repeat uint32
+		switch symbol {
+		case 16:
+			if i == 0 {
+				return ErrCorrupt
+			}
+			value = all[i-1]
+			repeat, err = f.readBits(2)
+			repeat += 3
+		case 17:
+			repeat, err = f.readBits(3)
+			repeat += 3
+		case 18:
+			repeat, err = f.readBits(7)
+			repeat += 11
+		default:
+			all[i] = uint8(symbol)
+			i++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if i+int(repeat) > len(all) {
+			return ErrCorrupt
+		}
+		for range repeat {
+			all[i] = value
+			i++
+		}
+	}
+
+	if all[256] == 0 {
+		// without an end of block code the block could never end
+		return ErrCorrupt
+	}
+	if err = f.literal.init(all[:literals]); err != nil {
+		return err
+	}
+	return f.distance.init(all[literals:])
+}
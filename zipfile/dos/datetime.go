@@ -25,7 +25,7 @@ func (t *Time) Set(v uint16) {
 	t.Hour = (v >> 11) & 0x1f
 }
 
-func (t *Time) Marshal(w io.WriteSeeker) error {
+func (t *Time) Marshal(w io.Writer) error {
 	return binary.Write(w, binary.LittleEndian, t.Get())
 }
 
@@ -69,7 +69,7 @@ func (d *Date) Set(v uint16) {
 	d.Year = ((v >> 9) & 0x7f) + 1980
 }
 
-func (d *Date) Marshal(w io.WriteSeeker) error {
+func (d *Date) Marshal(w io.Writer) error {
 	return binary.Write(w, binary.LittleEndian, d.Get())
 }
 
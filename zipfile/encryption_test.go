@@ -0,0 +1,148 @@
+package zipfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// readEntry reads the first entry of an archive with the given password and
+// returns the error of opening or reading it.
+func readEntry(t *testing.T, archive []byte, password string) error {
+	t.Helper()
+	zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr.SetPassword(password)
+	rc, err := zr.Entries[0].Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.ReadAll(rc)
+	return err
+}
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption uint8
+		password   string
+		want       error
+	}{
+		{"zipcrypto", EncryptionZipCrypto, "secret", nil},
+		{"zipcrypto wrong password", EncryptionZipCrypto, "wrong", ErrPassword},
+		{"zipcrypto no password", EncryptionZipCrypto, "", ErrPasswordRequired},
+		{"aes128", EncryptionAES128, "secret", nil},
+		{"aes192", EncryptionAES192, "secret", nil},
+		{"aes256", EncryptionAES256, "secret", nil},
+		{"aes wrong password", EncryptionAES256, "wrong", ErrPassword},
+		{"aes no password", EncryptionAES256, "", ErrPasswordRequired},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// stored, so that the rare wrong key that passes the check of the
+			// encryption header reads garbage instead of failing to decompress
+			archive := writeArchive(t, func(zw *Writer) {
+				zw.SetPassword("secret")
+				zw.SetEncryption(test.encryption)
+			}, CompressionMethodStored, "a.txt", "hello, world")
+
+			err := readEntry(t, archive, test.password)
+			var checksumErr *ChecksumError
+			if test.want == ErrPassword && (errors.As(err, &checksumErr) || errors.Is(err, ErrAuthentication)) {
+				// the encryption header only tells a wrong password 255 times out
+				// of 256 for ZipCrypto, 65535 for AES
+				return
+			}
+			if !errors.Is(err, test.want) {
+				t.Errorf("error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestAESTamper(t *testing.T) {
+	content := bytes.Repeat([]byte("text "), 100)
+	tests := []struct {
+		name string
+		hint uint64
+		// position of the altered byte from the end of the entry data, whose
+		// last 10 bytes are the authentication code
+		position int
+	}{
+		// AE-2 leaves the CRC-32 out, which only leaves the authentication code
+		{"AE-2 payload", 0, 11},
+		{"AE-2 authentication code", 0, 1},
+		{"AE-1 payload", uint64(len(content)), 11},
+		{"AE-1 authentication code", uint64(len(content)), 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := NewWriter(&buf)
+			zw.SetPassword("secret")
+			zw.SetEncryption(EncryptionAES256)
+			w, err := zw.Create(&FileHeader{Name: "a.txt", UncompressedSize: test.hint})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = w.Write(content); err != nil {
+				t.Fatal(err)
+			}
+			if err = zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			archive := buf.Bytes()
+			zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := zr.Entries[0]
+			offset, err := e.findDataOffset()
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive[offset+int64(e.CompressedSize)-int64(test.position)] ^= 1
+
+			if err = readEntry(t, archive, "secret"); !errors.Is(err, ErrAuthentication) {
+				t.Errorf("error %v, want %v", err, ErrAuthentication)
+			}
+		})
+	}
+}
+
+func TestAESVersionNeeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		method uint16
+		want   uint16
+	}{
+		{"deflated", CompressionMethodDeflated, 51},
+		{"bzip2", CompressionMethodBZIP2, 51},
+		{"zstd", CompressionMethodZSTD, 63},
+		{"xz", CompressionMethodXZ, 63},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := writeArchive(t, func(zw *Writer) {
+				zw.SetPassword("secret")
+				zw.SetEncryption(EncryptionAES256)
+			}, test.method, "a.txt", "hello, world")
+
+			zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := zr.Entries[0].VersionNeeded; got != test.want {
+				t.Errorf("version needed %d, want %d", got, test.want)
+			}
+			if err = readEntry(t, archive, "secret"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
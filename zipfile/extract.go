@@ -0,0 +1,382 @@
+package zipfile
+
+import (
+	"errors"
+	"fmt"
+	"go-zipfile/zipfile/dos"
+	"go-zipfile/zipfile/extrafield"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInsecurePath = errors.New("zip: insecure file path")
+
+// the longest link target extracted, PATH_MAX on Linux
+const maxSymlinkLength = 4096
+
+type ExtractOptions struct {
+	// Overwrite replaces existing files instead of failing on them.
+	Overwrite bool
+	// RestoreModTime applies the last modification time of the entry.
+	RestoreModTime bool
+	// RestorePermissions applies the POSIX mode kept in the upper half of the external attributes.
+	RestorePermissions bool
+	// RestoreSpecialBits also applies its set-user-ID, set-group-ID and sticky
+	// bits, which an untrusted archive could use to plant privileged programs.
+	RestoreSpecialBits bool
+	// RestoreOwnership applies the uid and gid of the UNIX extra field, which usually needs privileges.
+	RestoreOwnership bool
+	// RestoreNTFSTimes applies the write, access and creation times of the NTFS extra field.
+	RestoreNTFSTimes bool
+}
+
+func (zr *Reader) ExtractAll(dir string, opts ExtractOptions) (err error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(root, 0o755); err != nil {
+		return
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return
+	}
+
+	type directory struct {
+		entry  *Entry
+		target string
+	}
+	var directories []directory
+	var links []string
+
+	// a failing entry stops the extraction, but not the check of the links below
+	for _, entry := range zr.Entries {
+		var target string
+		if target, err = entry.extract(root, opts); err != nil {
+			break
+		}
+		if entry.IsDir() {
+			directories = append(directories, directory{entry, target})
+			continue
+		}
+		if entry.Mode()&os.ModeSymlink != 0 {
+			links = append(links, target)
+		}
+		if err = entry.restoreMetadata(target, opts); err != nil {
+			break
+		}
+	}
+
+	// a later link can change where an earlier one leads, "a" -> "b/.." then
+	// "b" -> ".", so each one is checked again once they all exist
+	for _, link := range links {
+		if linkErr := checkExtractedSymlink(root, link); linkErr != nil {
+			if removeErr := os.Remove(link); removeErr != nil {
+				return removeErr
+			}
+			if err == nil {
+				err = linkErr
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	// extracting files touches their parents, so directories are restored last, innermost first
+	for i := len(directories) - 1; i >= 0; i-- {
+		if err = directories[i].entry.restoreMetadata(directories[i].target, opts); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (e *Entry) extract(root string, opts ExtractOptions) (target string, err error) {
+	name, err := sanitizeName(e.Name)
+	if err != nil {
+		return
+	}
+
+	target = filepath.Join(root, filepath.FromSlash(name))
+	if err = checkSymlinks(root, target); err != nil {
+		return "", fmt.Errorf("%w: %s", err, e.Name)
+	}
+
+	if e.IsDir() {
+		err = os.MkdirAll(target, 0o755)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return
+	}
+
+	if opts.Overwrite {
+		if stat, statErr := os.Lstat(target); statErr == nil && !stat.IsDir() {
+			if err = os.Remove(target); err != nil {
+				return
+			}
+		}
+	}
+
+	if e.Mode()&os.ModeSymlink != 0 {
+		err = e.extractSymlink(root, target)
+		return
+	}
+
+	// O_EXCL never follows a symbolic link planted at the target itself
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	rc, err := e.Open()
+	if err != nil {
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	_, err = io.Copy(file, rc)
+	return
+}
+
+// extractSymlink creates a link to the target held by the entry, which has to
+// resolve within root.
+func (e *Entry) extractSymlink(root, target string) (err error) {
+	rc, err := e.Open()
+	if err != nil {
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	link, err := io.ReadAll(io.LimitReader(rc, maxSymlinkLength+1))
+	if err != nil {
+		return
+	}
+	if len(link) == 0 || len(link) > maxSymlinkLength {
+		return ErrFormat
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return
+	}
+	if err = checkSymlinkTarget(root, dir, string(link)); err != nil {
+		return fmt.Errorf("%w: %s -> %s", err, e.Name, link)
+	}
+	return os.Symlink(filepath.FromSlash(string(link)), target)
+}
+
+func (e *Entry) restoreMetadata(target string, opts ExtractOptions) (err error) {
+	if opts.RestoreOwnership {
+		// the Info-ZIP record is not limited to 16-bit IDs
+		var field extrafield.InfoZIPUnixExtraField
+		var legacy extrafield.UNIXExtraField
+		if findExtraField(e.ExtraField, &field) {
+			err = os.Lchown(target, int(field.Uid), int(field.Gid))
+		} else if findExtraField(e.ExtraField, &legacy) {
+			err = os.Lchown(target, int(legacy.Uid), int(legacy.Gid))
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	// the calls below would follow a link and change its target instead
+	if e.Mode()&os.ModeSymlink != 0 {
+		return
+	}
+
+	// chmod follows chown, which may clear the set-user-ID and set-group-ID bits
+	if opts.RestorePermissions {
+		if err = e.restorePermissions(target, opts.RestoreSpecialBits); err != nil {
+			return
+		}
+	}
+
+	if opts.RestoreModTime {
+		if err = os.Chtimes(target, e.Modified, e.Modified); err != nil {
+			return
+		}
+	}
+
+	if opts.RestoreNTFSTimes {
+		var field extrafield.NTFSExtraField
+		if findExtraField(e.ExtraField, &field) {
+			modified := extrafield.FiletimeToTime(field.Mtime)
+			accessed := extrafield.FiletimeToTime(field.Atime)
+			if err = os.Chtimes(target, accessed, modified); err != nil {
+				return
+			}
+			if err = setCreationTime(target, extrafield.FiletimeToTime(field.Ctime)); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (e *Entry) restorePermissions(target string, special bool) error {
+	if e.hasUnixMode() {
+		mask := os.ModePerm
+		if special {
+			mask |= os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+		}
+		return os.Chmod(target, e.Mode()&mask)
+	}
+
+	// DOS attributes only carry the read-only bit, the umask decided everything else
+	if e.ExternalFileAttributes&dos.FileAttributeReadonly == 0 {
+		return nil
+	}
+	stat, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(target, stat.Mode().Perm()&^0o222)
+}
+
+// findExtraField decodes the first record of the extra field block with the tag
+// of field into it.
+func findExtraField(extra []byte, field extrafield.Field) bool {
+	record := extrafield.Find(extra, field.HeaderID())
+	return record != nil && field.UnmarshalBinary(record) == nil
+}
+
+// sanitizeName validates an entry name and returns it cleaned, still slash separated.
+func sanitizeName(name string) (string, error) {
+	insecure := fmt.Errorf("%w: %s", ErrInsecurePath, name)
+
+	if len(name) == 0 || strings.ContainsAny(name, "\\\x00") {
+		return "", insecure
+	}
+	if strings.HasPrefix(name, "/") {
+		return "", insecure
+	}
+	if len(name) >= 2 && name[1] == ':' {
+		return "", insecure
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if component == ".." {
+			return "", insecure
+		}
+	}
+
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if cleaned == "." {
+		return ".", nil
+	}
+	if !filepath.IsLocal(cleaned) {
+		return "", insecure
+	}
+	return filepath.ToSlash(cleaned), nil
+}
+
+// checkSymlinks rejects targets that would be reached through a symbolic link leaving root.
+func checkSymlinks(root, target string) error {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+
+	path := root
+	for _, component := range strings.Split(rel, string(filepath.Separator)) {
+		path = filepath.Join(path, component)
+
+		stat, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if stat.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			// dangling links could be completed later to point anywhere
+			return ErrInsecurePath
+		}
+		if !IsWithin(root, resolved) {
+			return ErrInsecurePath
+		}
+	}
+	return nil
+}
+
+// checkSymlinkTarget follows the target of a link created in dir component by
+// component, through the links already extracted, and rejects it if any step
+// leaves root. Checking the cleaned path alone would miss "link/.." where link
+// points elsewhere.
+func checkSymlinkTarget(root, dir, link string) error {
+	if strings.ContainsRune(link, '\x00') || strings.HasPrefix(link, "/") || filepath.IsAbs(filepath.FromSlash(link)) || filepath.VolumeName(filepath.FromSlash(link)) != "" {
+		return ErrInsecurePath
+	}
+	if !IsWithin(root, dir) {
+		return ErrInsecurePath
+	}
+
+	path := dir
+	for _, component := range strings.Split(filepath.FromSlash(link), string(filepath.Separator)) {
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			path = filepath.Dir(path)
+		default:
+			path = filepath.Join(path, component)
+			if stat, err := os.Lstat(path); err == nil && stat.Mode()&os.ModeSymlink != 0 {
+				resolved, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					return ErrInsecurePath
+				}
+				path = resolved
+			}
+		}
+		if !IsWithin(root, path) {
+			return ErrInsecurePath
+		}
+	}
+	return nil
+}
+
+// checkExtractedSymlink checks the target of a link that is already created.
+func checkExtractedSymlink(root, path string) error {
+	link, err := os.Readlink(path)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return ErrInsecurePath
+	}
+	if err = checkSymlinkTarget(root, dir, filepath.ToSlash(link)); err != nil {
+		rel, _ := filepath.Rel(root, path)
+		return fmt.Errorf("%w: %s -> %s", err, filepath.ToSlash(rel), link)
+	}
+	return nil
+}
+
+// IsWithin tells whether path is root or lies under it, judged by their names
+// alone, without following links.
+func IsWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || filepath.IsLocal(rel)
+}
@@ -0,0 +1,112 @@
+package zipfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"go-zipfile/zipfile/posix"
+)
+
+// symlinkArchive returns an archive of links, given as name and target pairs.
+func symlinkArchive(t *testing.T, links ...string) *Reader {
+	t.Helper()
+	return unixArchive(t, posix.StatIsSymbolicLink|0o777, links...)
+}
+
+// unixArchive returns an archive of entries of the given POSIX mode, given as
+// name and content pairs.
+func unixArchive(t *testing.T, mode uint16, entries ...string) *Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < len(entries); i += 2 {
+		fw, err := w.Create(&FileHeader{
+			Name:                   entries[i],
+			Version:                uint16(VersionMadeByUNIX)<<8 | LatestVersion,
+			ExternalFileAttributes: uint32(mode) << 16,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fw.Write([]byte(entries[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	zr := symlinkArchive(t, "pkg/tool", "../bin/tool", "bin/", "")
+	if err := zr.ExtractAll(dir, ExtractOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "pkg", "tool")); err != nil || link != filepath.FromSlash("../bin/tool") {
+		t.Fatalf("link = %q, %v", link, err)
+	}
+}
+
+func TestExtractSymlinkEscapes(t *testing.T) {
+	tests := map[string][]string{
+		"absolute": {"a", "/etc/passwd"},
+		"parent":   {"a", "../outside"},
+		"through":  {"a", ".", "b", "a/.."},
+		// "a" passes while "b" does not exist, then "b" makes it lead out
+		"retarget": {"a", "b/..", "b", "."},
+		// the insecure name stops the extraction before the links are checked again
+		"retarget then fail": {"a", "b/..", "b", ".", "../evil", "x"},
+	}
+	for name, links := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "out")
+			err := symlinkArchive(t, links...).ExtractAll(dir, ExtractOptions{})
+			if !errors.Is(err, ErrInsecurePath) {
+				t.Fatalf("err = %v, want %v", err, ErrInsecurePath)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				path := filepath.Join(dir, entry.Name())
+				if resolved, err := filepath.EvalSymlinks(path); err == nil && !IsWithin(dir, resolved) {
+					t.Errorf("%s leads to %s", entry.Name(), resolved)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractSpecialBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no POSIX modes on Windows")
+	}
+	for _, special := range []bool{false, true} {
+		dir := t.TempDir()
+		zr := unixArchive(t, posix.StatIsRegularFile|posix.StatIsSetUserID|0o755, "tool", "#!/bin/sh\n")
+		if err := zr.ExtractAll(dir, ExtractOptions{RestorePermissions: true, RestoreSpecialBits: special}); err != nil {
+			t.Fatal(err)
+		}
+		stat, err := os.Stat(filepath.Join(dir, "tool"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := os.FileMode(0o755)
+		if special {
+			want |= os.ModeSetuid
+		}
+		if stat.Mode() != want {
+			t.Errorf("special %v: mode = %v, want %v", special, stat.Mode(), want)
+		}
+	}
+}
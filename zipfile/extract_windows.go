@@ -0,0 +1,31 @@
+//go:build windows
+
+package zipfile
+
+import (
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func setCreationTime(path string, creationTime time.Time) error {
+	if creationTime.IsZero() {
+		return nil
+	}
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(path),
+		windows.FILE_WRITE_ATTRIBUTES,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS,
+		0,
+	)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = windows.CloseHandle(handle) }()
+
+	filetime := windows.NsecToFiletime(creationTime.UnixNano())
+	return windows.SetFileTime(handle, &filetime, nil, nil)
+}
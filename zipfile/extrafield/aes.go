@@ -0,0 +1,36 @@
+package extrafield
+
+// AESTagType is the WinZip AES extra field, which records the compression
+// method actually used by entries whose method is 99 (AE-x).
+const (
+	AESTagType  uint16 = 0x9901
+	AESVendorID uint16 = 0x4541 // "AE"
+)
+
+const (
+	// AESVersion1 entries keep their CRC-32
+	AESVersion1 uint16 = 1
+	// AESVersion2 entries set their CRC-32 to zero, since it could reveal a short plaintext
+	AESVersion2 uint16 = 2
+)
+
+type AESExtraField struct {
+	Tag               uint16
+	TSize             uint16
+	Version           uint16
+	VendorID          uint16
+	Strength          uint8
+	CompressionMethod uint16
+}
+
+func (f *AESExtraField) HeaderID() uint16 {
+	return AESTagType
+}
+
+func (f *AESExtraField) MarshalBinary() ([]byte, error) {
+	return marshalFixed(AESTagType, f)
+}
+
+func (f *AESExtraField) UnmarshalBinary(record []byte) error {
+	return unmarshalFixed(record, AESTagType, f)
+}
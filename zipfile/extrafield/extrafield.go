@@ -0,0 +1,36 @@
+package extrafield
+
+import "encoding/binary"
+
+// Find returns the first record with the given tag in a raw extra field block,
+// header included, or nil if there is none.
+func Find(extra []byte, tag uint16) []byte {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:])
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			return nil
+		}
+		if id == tag {
+			return extra[:4+size]
+		}
+		extra = extra[4+size:]
+	}
+	return nil
+}
+
+// Remove returns the extra field block without the records with the given tag.
+func Remove(extra []byte, tag uint16) []byte {
+	var kept []byte
+	for len(extra) >= 4 {
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			break
+		}
+		if binary.LittleEndian.Uint16(extra[0:]) != tag {
+			kept = append(kept, extra[:4+size]...)
+		}
+		extra = extra[4+size:]
+	}
+	return append(kept, extra...)
+}
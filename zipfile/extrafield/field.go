@@ -0,0 +1,189 @@
+package extrafield
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrFormat   = errors.New("zip: invalid extra field")
+	ErrTooLarge = errors.New("zip: extra field too large")
+)
+
+// Field is a typed extra field record. Its binary form is the whole record,
+// the 4-byte header of tag and data size included.
+type Field interface {
+	// HeaderID returns the tag of the record, even on the zero value.
+	HeaderID() uint16
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(record []byte) error
+}
+
+var fields sync.Map // map[uint16]func() Field
+
+func init() {
+	Register(Zip64TagType, func() Field { return &Zip64ExtraField{} })
+	Register(NTFSTagType, func() Field { return &NTFSExtraField{} })
+	Register(UNIXTagType, func() Field { return &UNIXExtraField{} })
+	Register(AESTagType, func() Field { return &AESExtraField{} })
+	Register(ExtendedTimestampTagType, func() Field { return &ExtendedTimestampExtraField{} })
+	Register(InfoZIPUnixTagType, func() Field { return &InfoZIPUnixExtraField{} })
+	Register(UnicodePathTagType, func() Field { return &UnicodePathExtraField{} })
+	Register(UnicodeCommentTagType, func() Field { return &UnicodeCommentExtraField{} })
+}
+
+// Register makes Parse decode the records with the given tag into the fields
+// returned by newField. Registering a tag twice panics.
+func Register(tag uint16, newField func() Field) {
+	if _, loaded := fields.LoadOrStore(tag, newField); loaded {
+		panic(fmt.Sprintf("zip: extra field already registered for tag 0x%04x", tag))
+	}
+}
+
+// Fields are the records of an extra field block, in their order.
+type Fields []Field
+
+// Parse splits a raw extra field block into its records. Records of registered
+// tags that decode are typed, the others are kept as Unknown, byte for byte.
+func Parse(extra []byte) (Fields, error) {
+	var parsed Fields
+	for len(extra) > 0 {
+		if len(extra) < 4 {
+			return parsed, ErrFormat
+		}
+		tag := binary.LittleEndian.Uint16(extra[0:])
+		size := int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			return parsed, ErrFormat
+		}
+		record := extra[:4+size]
+		extra = extra[4+size:]
+
+		if newField, ok := fields.Load(tag); ok {
+			field := newField.(func() Field)()
+			if field.UnmarshalBinary(record) == nil {
+				parsed = append(parsed, field)
+				continue
+			}
+		}
+		parsed = append(parsed, &Unknown{Tag: tag, Data: append([]byte(nil), record[4:]...)})
+	}
+	return parsed, nil
+}
+
+// Serialize returns the raw extra field block of the records, sizing each one
+// by its data.
+func Serialize(fields Fields) ([]byte, error) {
+	var extra []byte
+	for _, field := range fields {
+		record, err := field.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		extra = append(extra, record...)
+	}
+	if len(extra) > 0xffff {
+		return nil, ErrTooLarge
+	}
+	return extra, nil
+}
+
+// Get returns the first record with the given tag, or nil if there is none.
+func (fs Fields) Get(tag uint16) Field {
+	for _, field := range fs {
+		if field.HeaderID() == tag {
+			return field
+		}
+	}
+	return nil
+}
+
+// Set replaces the records with the tag of field by field, in place of the
+// first one, or appends it if there is none.
+func (fs Fields) Set(field Field) Fields {
+	tag := field.HeaderID()
+	for i := range fs {
+		if fs[i].HeaderID() == tag {
+			fs[i] = field
+			return append(fs[:i+1], fs[i+1:].Delete(tag)...)
+		}
+	}
+	return append(fs, field)
+}
+
+// Delete returns the fields without the records with the given tag.
+func (fs Fields) Delete(tag uint16) Fields {
+	kept := fs[:0]
+	for _, field := range fs {
+		if field.HeaderID() != tag {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
+
+// Unknown is a record of a tag that is not registered, or that did not decode.
+type Unknown struct {
+	Tag  uint16
+	Data []byte
+}
+
+func (u *Unknown) HeaderID() uint16 {
+	return u.Tag
+}
+
+func (u *Unknown) MarshalBinary() ([]byte, error) {
+	return appendRecord(nil, u.Tag, u.Data)
+}
+
+func (u *Unknown) UnmarshalBinary(record []byte) error {
+	tag, data, err := splitRecord(record)
+	if err != nil {
+		return err
+	}
+	u.Tag, u.Data = tag, append([]byte(nil), data...)
+	return nil
+}
+
+// appendRecord appends a record header sized by data, then data.
+func appendRecord(extra []byte, tag uint16, data []byte) ([]byte, error) {
+	if len(data) > 0xffff-4 {
+		return nil, ErrTooLarge
+	}
+	extra = binary.LittleEndian.AppendUint16(extra, tag)
+	extra = binary.LittleEndian.AppendUint16(extra, uint16(len(data)))
+	return append(extra, data...), nil
+}
+
+// splitRecord returns the tag and the data of a whole record.
+func splitRecord(record []byte) (tag uint16, data []byte, err error) {
+	if len(record) < 4 || int(binary.LittleEndian.Uint16(record[2:])) != len(record)-4 {
+		return 0, nil, ErrFormat
+	}
+	return binary.LittleEndian.Uint16(record), record[4:], nil
+}
+
+// marshalFixed encodes a fixed size record whose first fields are its tag and
+// size, which are set from tag and the size of the rest.
+func marshalFixed(tag uint16, field any) ([]byte, error) {
+	record, err := binary.Append(nil, binary.LittleEndian, field)
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint16(record[0:], tag)
+	binary.LittleEndian.PutUint16(record[2:], uint16(len(record)-4))
+	return record, nil
+}
+
+// unmarshalFixed decodes a fixed size record that has to fill field exactly.
+func unmarshalFixed(record []byte, tag uint16, field any) error {
+	if recordTag, _, err := splitRecord(record); err != nil || recordTag != tag {
+		return ErrFormat
+	}
+	if n, err := binary.Decode(record, binary.LittleEndian, field); err != nil || n != len(record) {
+		return ErrFormat
+	}
+	return nil
+}
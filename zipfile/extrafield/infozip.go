@@ -0,0 +1,182 @@
+package extrafield
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// ExtendedTimestampTagType is the Info-ZIP extended timestamp, UTC times in
+// signed 32-bit seconds since 1970. The local header holds every time its flags announce,
+// the central header only the modification time.
+const (
+	ExtendedTimestampTagType uint16 = 0x5455
+)
+
+const (
+	ExtendedTimestampModified uint8 = 1 << iota
+	ExtendedTimestampAccessed
+	ExtendedTimestampCreated
+)
+
+type ExtendedTimestampExtraField struct {
+	Tag      uint16
+	TSize    uint16
+	Flags    uint8
+	Modified int32
+	Accessed int32
+	Created  int32
+	// Central records only hold Modified, whatever Flags announces.
+	Central bool
+}
+
+// NewExtendedTimestampExtraField returns a local record of the times, leaving
+// out an access time that does not fit, or nil if the modification time does
+// not, from before 1901-12-13 or after 2038-01-19.
+func NewExtendedTimestampExtraField(modified, accessed time.Time) *ExtendedTimestampExtraField {
+	seconds, ok := timeToUnix(modified)
+	if !ok {
+		return nil
+	}
+	f := &ExtendedTimestampExtraField{Flags: ExtendedTimestampModified, Modified: seconds}
+	if seconds, ok := timeToUnix(accessed); ok {
+		f.Flags |= ExtendedTimestampAccessed
+		f.Accessed = seconds
+	}
+	return f
+}
+
+func timeToUnix(t time.Time) (int32, bool) {
+	if t.IsZero() || t.Unix() < math.MinInt32 || t.Unix() > math.MaxInt32 {
+		return 0, false
+	}
+	return int32(t.Unix()), true
+}
+
+// ModTime returns the modification time, or the zero time if there is none.
+func (f *ExtendedTimestampExtraField) ModTime() time.Time {
+	if f.Flags&ExtendedTimestampModified == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(f.Modified), 0)
+}
+
+func (f *ExtendedTimestampExtraField) HeaderID() uint16 {
+	return ExtendedTimestampTagType
+}
+
+func (f *ExtendedTimestampExtraField) MarshalBinary() ([]byte, error) {
+	data := []byte{f.Flags}
+	for i, value := range f.times() {
+		if f.Flags&(1<<i) == 0 || f.Central && i > 0 {
+			continue
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(*value))
+	}
+	return appendRecord(nil, ExtendedTimestampTagType, data)
+}
+
+// UnmarshalBinary decodes both forms, telling them apart by their size.
+func (f *ExtendedTimestampExtraField) UnmarshalBinary(record []byte) error {
+	tag, data, err := splitRecord(record)
+	if err != nil || tag != ExtendedTimestampTagType || len(data) < 1 {
+		return ErrFormat
+	}
+	f.Tag, f.TSize, f.Flags = tag, uint16(len(data)), data[0]
+	f.Modified, f.Accessed, f.Created = 0, 0, 0
+	data = data[1:]
+
+	local := 4 * bits.OnesCount8(f.Flags&(ExtendedTimestampModified|ExtendedTimestampAccessed|ExtendedTimestampCreated))
+	central := 4 * int(f.Flags&ExtendedTimestampModified)
+	switch len(data) {
+	case local:
+		f.Central = false
+	case central:
+		f.Central = true
+	default:
+		return ErrFormat
+	}
+
+	for i, value := range f.times() {
+		if f.Flags&(1<<i) == 0 || len(data) == 0 {
+			continue
+		}
+		*value = int32(binary.LittleEndian.Uint32(data))
+		data = data[4:]
+	}
+	return nil
+}
+
+// times returns the times in the order of their flags.
+func (f *ExtendedTimestampExtraField) times() [3]*int32 {
+	return [3]*int32{&f.Modified, &f.Accessed, &f.Created}
+}
+
+// InfoZIPUnixTagType is the Info-ZIP Unix record of version 1, which holds the
+// owner and group IDs with their size, unlike the 16-bit ones of UNIXExtraField.
+const (
+	InfoZIPUnixTagType uint16 = 0x7875
+	InfoZIPUnixVersion uint8  = 1
+)
+
+type InfoZIPUnixExtraField struct {
+	Tag     uint16
+	TSize   uint16
+	Version uint8
+	UidSize uint8
+	Uid     uint64
+	GidSize uint8
+	Gid     uint64
+}
+
+// NewInfoZIPUnixExtraField returns a record holding 4-byte IDs, as Info-ZIP does.
+func NewInfoZIPUnixExtraField(uid, gid uint32) *InfoZIPUnixExtraField {
+	return &InfoZIPUnixExtraField{
+		Version: InfoZIPUnixVersion,
+		UidSize: 4,
+		Uid:     uint64(uid),
+		GidSize: 4,
+		Gid:     uint64(gid),
+	}
+}
+
+func (f *InfoZIPUnixExtraField) HeaderID() uint16 {
+	return InfoZIPUnixTagType
+}
+
+func (f *InfoZIPUnixExtraField) MarshalBinary() ([]byte, error) {
+	if f.UidSize > 8 || f.GidSize > 8 {
+		return nil, ErrFormat
+	}
+	data := []byte{f.Version, f.UidSize}
+	data = append(data, binary.LittleEndian.AppendUint64(nil, f.Uid)[:f.UidSize]...)
+	data = append(data, f.GidSize)
+	data = append(data, binary.LittleEndian.AppendUint64(nil, f.Gid)[:f.GidSize]...)
+	return appendRecord(nil, InfoZIPUnixTagType, data)
+}
+
+func (f *InfoZIPUnixExtraField) UnmarshalBinary(record []byte) error {
+	tag, data, err := splitRecord(record)
+	if err != nil || tag != InfoZIPUnixTagType || len(data) < 2 || data[0] != InfoZIPUnixVersion {
+		return ErrFormat
+	}
+	f.Tag, f.TSize, f.Version = tag, uint16(len(data)), data[0]
+	data = data[1:]
+
+	readID := func(size *uint8, id *uint64) bool {
+		if len(data) < 1 || data[0] > 8 || len(data) < 1+int(data[0]) {
+			return false
+		}
+		var buf [8]byte
+		*size = data[0]
+		copy(buf[:], data[1:1+*size])
+		*id = binary.LittleEndian.Uint64(buf[:])
+		data = data[1+*size:]
+		return true
+	}
+	if !readID(&f.UidSize, &f.Uid) || !readID(&f.GidSize, &f.Gid) || len(data) != 0 {
+		return ErrFormat
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package extrafield
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtendedTimestampSigned(t *testing.T) {
+	modified := time.Date(1965, time.March, 4, 5, 6, 7, 0, time.UTC)
+	record, err := NewExtendedTimestampExtraField(modified, time.Time{}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var field ExtendedTimestampExtraField
+	if err = field.UnmarshalBinary(record); err != nil {
+		t.Fatal(err)
+	}
+	if !field.ModTime().Equal(modified) {
+		t.Errorf("ModTime() = %v, want %v", field.ModTime(), modified)
+	}
+	if field.Flags != ExtendedTimestampModified {
+		t.Errorf("Flags = %#x, want %#x", field.Flags, ExtendedTimestampModified)
+	}
+}
+
+func TestExtendedTimestampOutOfRange(t *testing.T) {
+	late := time.Date(2040, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if field := NewExtendedTimestampExtraField(late, time.Time{}); field != nil {
+		t.Errorf("got a record for %v", late)
+	}
+
+	field := NewExtendedTimestampExtraField(time.Unix(1e9, 0), late)
+	if field == nil || field.Flags != ExtendedTimestampModified {
+		t.Errorf("the access time %v was not left out: %+v", late, field)
+	}
+}
@@ -1,5 +1,7 @@
 package extrafield
 
+import "time"
+
 const (
 	NTFSTagType       uint16 = 0x000a
 	NTFSAttribute1Tag        = 0x0001
@@ -15,3 +17,54 @@ type NTFSExtraField struct {
 	Atime    uint64
 	Ctime    uint64
 }
+
+// number of 100-nanosecond intervals between 1601-01-01 and 1970-01-01
+const filetimeEpochOffset = 116444736000000000
+
+// FiletimeToTime returns the zero time for 0, which stands for no time.
+func FiletimeToTime(filetime uint64) time.Time {
+	if filetime == 0 {
+		return time.Time{}
+	}
+	ticks := int64(filetime - filetimeEpochOffset)
+	return time.Unix(ticks/1e7, ticks%1e7*100)
+}
+
+// TimeToFiletime returns 0, which stands for no time, for the zero time.
+func TimeToFiletime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix()*1e7+int64(t.Nanosecond()/100)) + filetimeEpochOffset
+}
+
+// NewNTFSExtraField returns a record holding the times in attribute 1.
+func NewNTFSExtraField(modified, accessed, created time.Time) *NTFSExtraField {
+	return &NTFSExtraField{
+		Tag1:  NTFSAttribute1Tag,
+		Size1: 24,
+		Mtime: TimeToFiletime(modified),
+		Atime: TimeToFiletime(accessed),
+		Ctime: TimeToFiletime(created),
+	}
+}
+
+func (f *NTFSExtraField) HeaderID() uint16 {
+	return NTFSTagType
+}
+
+func (f *NTFSExtraField) MarshalBinary() ([]byte, error) {
+	return marshalFixed(NTFSTagType, f)
+}
+
+// UnmarshalBinary only decodes records holding the attribute 1 alone, the
+// layout every archiver writes.
+func (f *NTFSExtraField) UnmarshalBinary(record []byte) error {
+	if err := unmarshalFixed(record, NTFSTagType, f); err != nil {
+		return err
+	}
+	if f.Tag1 != NTFSAttribute1Tag || f.Size1 != 24 {
+		return ErrFormat
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package extrafield
+
+import "encoding/binary"
+
+// UnicodePathTagType and UnicodeCommentTagType are the Info-ZIP records of
+// version 1 holding the UTF-8 name or comment of an entry whose header does not
+// set the UTF-8 flag. The CRC-32 of the name or comment of the header tells
+// whether they still match, since another tool may have renamed the entry.
+const (
+	UnicodePathTagType    uint16 = 0x7075
+	UnicodeCommentTagType uint16 = 0x6375
+	UnicodeVersion        uint8  = 1
+)
+
+type UnicodePathExtraField struct {
+	Tag     uint16
+	TSize   uint16
+	Version uint8
+	// NameCRC32 is the CRC-32 of the name in the header.
+	NameCRC32   uint32
+	UnicodeName string
+}
+
+func (f *UnicodePathExtraField) HeaderID() uint16 {
+	return UnicodePathTagType
+}
+
+func (f *UnicodePathExtraField) MarshalBinary() ([]byte, error) {
+	return marshalUnicode(UnicodePathTagType, f.Version, f.NameCRC32, f.UnicodeName)
+}
+
+func (f *UnicodePathExtraField) UnmarshalBinary(record []byte) error {
+	data, err := unicodeData(UnicodePathTagType, record)
+	if err != nil {
+		return err
+	}
+	f.Tag, f.TSize = UnicodePathTagType, uint16(len(data))
+	f.Version, f.NameCRC32, f.UnicodeName = data[0], binary.LittleEndian.Uint32(data[1:]), string(data[5:])
+	return nil
+}
+
+type UnicodeCommentExtraField struct {
+	Tag     uint16
+	TSize   uint16
+	Version uint8
+	// CommentCRC32 is the CRC-32 of the comment in the header.
+	CommentCRC32   uint32
+	UnicodeComment string
+}
+
+func (f *UnicodeCommentExtraField) HeaderID() uint16 {
+	return UnicodeCommentTagType
+}
+
+func (f *UnicodeCommentExtraField) MarshalBinary() ([]byte, error) {
+	return marshalUnicode(UnicodeCommentTagType, f.Version, f.CommentCRC32, f.UnicodeComment)
+}
+
+func (f *UnicodeCommentExtraField) UnmarshalBinary(record []byte) error {
+	data, err := unicodeData(UnicodeCommentTagType, record)
+	if err != nil {
+		return err
+	}
+	f.Tag, f.TSize = UnicodeCommentTagType, uint16(len(data))
+	f.Version, f.CommentCRC32, f.UnicodeComment = data[0], binary.LittleEndian.Uint32(data[1:]), string(data[5:])
+	return nil
+}
+
+func marshalUnicode(tag uint16, version uint8, crc uint32, text string) ([]byte, error) {
+	data := binary.LittleEndian.AppendUint32([]byte{version}, crc)
+	return appendRecord(nil, tag, append(data, text...))
+}
+
+// unicodeData returns the data of a record of version 1.
+func unicodeData(tag uint16, record []byte) ([]byte, error) {
+	recordTag, data, err := splitRecord(record)
+	if err != nil || recordTag != tag || len(data) < 5 || data[0] != UnicodeVersion {
+		return nil, ErrFormat
+	}
+	return data, nil
+}
@@ -1,9 +1,13 @@
 package extrafield
 
+import "encoding/binary"
+
 const (
 	UNIXTagType uint16 = 0x000d
 )
 
+// UNIXExtraField is the PKWARE Unix record. Data holds what follows the fixed
+// fields, the target of a link or the major and minor numbers of a device.
 type UNIXExtraField struct {
 	Tag   uint16
 	TSize uint16
@@ -11,4 +15,31 @@ type UNIXExtraField struct {
 	Mtime uint32
 	Uid   uint16
 	Gid   uint16
+	Data  []byte
+}
+
+func (f *UNIXExtraField) HeaderID() uint16 {
+	return UNIXTagType
+}
+
+func (f *UNIXExtraField) MarshalBinary() ([]byte, error) {
+	data := binary.LittleEndian.AppendUint32(nil, f.Atime)
+	data = binary.LittleEndian.AppendUint32(data, f.Mtime)
+	data = binary.LittleEndian.AppendUint16(data, f.Uid)
+	data = binary.LittleEndian.AppendUint16(data, f.Gid)
+	return appendRecord(nil, UNIXTagType, append(data, f.Data...))
+}
+
+func (f *UNIXExtraField) UnmarshalBinary(record []byte) error {
+	tag, data, err := splitRecord(record)
+	if err != nil || tag != UNIXTagType || len(data) < 12 {
+		return ErrFormat
+	}
+	f.Tag, f.TSize = tag, uint16(len(data))
+	f.Atime = binary.LittleEndian.Uint32(data[0:])
+	f.Mtime = binary.LittleEndian.Uint32(data[4:])
+	f.Uid = binary.LittleEndian.Uint16(data[8:])
+	f.Gid = binary.LittleEndian.Uint16(data[10:])
+	f.Data = append([]byte(nil), data[12:]...)
+	return nil
 }
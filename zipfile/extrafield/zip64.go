@@ -0,0 +1,43 @@
+package extrafield
+
+import "encoding/binary"
+
+// Zip64TagType is the ZIP64 extended information extra field. Its records only
+// hold the 8-byte values whose header fields are set to 0xffffffff, in the order
+// uncompressed size, compressed size, local header offset, disk start number.
+const (
+	Zip64TagType uint16 = 0x0001
+)
+
+type Zip64ExtraField struct {
+	Tag    uint16
+	TSize  uint16
+	Values []uint64
+}
+
+func (f *Zip64ExtraField) HeaderID() uint16 {
+	return Zip64TagType
+}
+
+func (f *Zip64ExtraField) MarshalBinary() ([]byte, error) {
+	var data []byte
+	for _, value := range f.Values {
+		data = binary.LittleEndian.AppendUint64(data, value)
+	}
+	return appendRecord(nil, Zip64TagType, data)
+}
+
+// UnmarshalBinary only decodes records of 8-byte values, those with a 4-byte
+// disk start number are kept as Unknown by Parse.
+func (f *Zip64ExtraField) UnmarshalBinary(record []byte) error {
+	tag, data, err := splitRecord(record)
+	if err != nil || tag != Zip64TagType || len(data)%8 != 0 {
+		return ErrFormat
+	}
+	f.Tag, f.TSize = tag, uint16(len(data))
+	f.Values = nil
+	for ; len(data) > 0; data = data[8:] {
+		f.Values = append(f.Values, binary.LittleEndian.Uint64(data))
+	}
+	return nil
+}
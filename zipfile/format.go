@@ -1,7 +1,11 @@
 package zipfile
 
 import (
+	"bytes"
 	"go-zipfile/zipfile/dos"
+	"go-zipfile/zipfile/extrafield"
+	"io"
+	"os"
 )
 
 type Signature [4]byte
@@ -39,25 +43,104 @@ func (lfh *LocalFileHeader) SizeOf() uint32 {
 		uint32(lfh.ExtraFieldLength) /* extra field (variable size)  */
 }
 
-/*
-TODO: lazy reader/writer
+// ExtraFields parses the extra field block of the header.
+func (lfh *LocalFileHeader) ExtraFields() (extrafield.Fields, error) {
+	return extrafield.Parse(lfh.ExtraField)
+}
+
+// SetExtraFields replaces the extra field block of the header by the records.
+func (lfh *LocalFileHeader) SetExtraFields(fields extrafield.Fields) error {
+	extra, err := extrafield.Serialize(fields)
+	if err != nil {
+		return err
+	}
+	lfh.ExtraField, lfh.ExtraFieldLength = extra, uint16(len(extra))
+	return nil
+}
 
+// FileData refers to entry data without holding it in memory, either a range
+// of a file that is only opened when the data is read, or a range of an io.ReaderAt.
 type FileData struct {
-	file   *os.File
+	path   string
+	reader io.ReaderAt
 	offset int64
 	length int64
 }
 
-func (fd *FileData) Unmarshal(reader io.ReadSeeker) error {
-	panic("implement me")
+func NewFileData(path string, offset, length int64) FileData {
+	return FileData{path: path, offset: offset, length: length}
+}
+
+func NewFileDataAt(reader io.ReaderAt, offset, length int64) FileData {
+	return FileData{reader: reader, offset: offset, length: length}
+}
+
+func (fd FileData) Len() int64 {
+	return fd.length
+}
+
+// section returns length bytes of the data from offset.
+func (fd FileData) section(offset, length int64) FileData {
+	fd.offset += offset
+	fd.length = length
+	return fd
 }
 
-func (fd *FileData) Marshal(writer io.WriteSeeker) error {
-	panic("implement me")
+func (fd FileData) Open() (io.ReadCloser, error) {
+	if fd.reader != nil {
+		return io.NopCloser(io.NewSectionReader(fd.reader, fd.offset, fd.length)), nil
+	}
+	if len(fd.path) == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	file, err := os.Open(fd.path)
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, fd.offset, fd.length), file}, nil
+}
+
+func (fd *FileData) UnmarshalStream(reader io.ReadSeeker, length int64) (err error) {
+	offset, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+
+	if readerAt, ok := reader.(io.ReaderAt); ok {
+		*fd = NewFileDataAt(readerAt, offset, length)
+		_, err = reader.Seek(length, io.SeekCurrent)
+		return
+	}
+
+	// without random access the data has to be read now
+	data := make([]byte, length)
+	if _, err = io.ReadFull(reader, data); err != nil {
+		return
+	}
+	*fd = NewFileDataAt(bytes.NewReader(data), 0, length)
+	return
 }
-*/
 
-type FileData []byte
+func (fd FileData) Marshal(writer io.Writer) (err error) {
+	rc, err := fd.Open()
+	if err != nil {
+		return
+	}
+	defer func() { _ = rc.Close() }()
+
+	_, err = io.Copy(writer, rc)
+	return
+}
+
+// SizeOf saturates at 0xffffffff, the value headers hold in place of the
+// sizes of 4 GiB and more, whose actual size goes in a ZIP64 record.
+func (fd FileData) SizeOf() uint32 {
+	return uint32(min(fd.length, uint32max))
+}
 
 type DataDescriptor struct {
 	CRC32            uint32
@@ -65,6 +148,12 @@ type DataDescriptor struct {
 	UncompressedSize uint32
 }
 
+type Zip64DataDescriptor struct {
+	CRC32            uint32
+	CompressedSize   uint64
+	UncompressedSize uint64
+}
+
 type CentralDirectoryFileHeader struct {
 	Signature              Signature
 	Version                uint16
@@ -112,6 +201,21 @@ func (cdf *CentralDirectoryFileHeader) SizeOf() uint32 {
 		uint32(cdf.FileCommentLength) /*  file comment (variable size) */
 }
 
+// ExtraFields parses the extra field block of the header.
+func (cdf *CentralDirectoryFileHeader) ExtraFields() (extrafield.Fields, error) {
+	return extrafield.Parse(cdf.ExtraField)
+}
+
+// SetExtraFields replaces the extra field block of the header by the records.
+func (cdf *CentralDirectoryFileHeader) SetExtraFields(fields extrafield.Fields) error {
+	extra, err := extrafield.Serialize(fields)
+	if err != nil {
+		return err
+	}
+	cdf.ExtraField, cdf.ExtraFieldLength = extra, uint16(len(extra))
+	return nil
+}
+
 type DigitalSignature struct {
 	Signature     Signature `serial:"prefix='PK\x05\x05'"`
 	DataSize      uint16
@@ -149,6 +253,59 @@ func (eocdr *EndOfCentralDirectoryRecord) SizeOf() uint32 {
 
 }
 
+type Zip64EndOfCentralDirectoryRecord struct {
+	Signature                  Signature
+	RecordSize                 uint64
+	Version                    uint16
+	VersionNeeded              uint16
+	DiskNumber                 uint32
+	StartingDiskNumber         uint32
+	DiskTotalEntries           uint64
+	TotalEntries               uint64
+	CentralDirectorySize       uint64
+	OffsetOfStartingDiskNumber uint64
+}
+
+func (z64eocdr *Zip64EndOfCentralDirectoryRecord) SizeOf() uint32 {
+	return 0 +
+		4 + /* zip64 end of central dir                                       */
+		0 + /* signature                       4 bytes  (0x06064b50)          */
+		8 + /* size of zip64 end of central                                   */
+		0 + /* directory record                8 bytes                        */
+		2 + /* version made by                 2 bytes                        */
+		2 + /* version needed to extract       2 bytes                        */
+		4 + /* number of this disk             4 bytes                        */
+		0 + /* number of the disk with the                                    */
+		4 + /* start of the central directory  4 bytes                        */
+		0 + /* total number of entries in the                                 */
+		8 + /* central directory on this disk  8 bytes                        */
+		0 + /* total number of entries in the                                 */
+		8 + /* central directory               8 bytes                        */
+		8 + /* size of the central directory   8 bytes                        */
+		0 + /* offset of start of central                                     */
+		0 + /* directory with respect to                                      */
+		8 /*   the starting disk number        8 bytes                        */
+}
+
+type Zip64EndOfCentralDirectoryLocator struct {
+	Signature                                Signature
+	StartingDiskNumber                       uint32
+	OffsetOfZip64EndOfCentralDirectoryRecord uint64
+	TotalDisks                               uint32
+}
+
+func (z64eocdl *Zip64EndOfCentralDirectoryLocator) SizeOf() uint32 {
+	return 0 +
+		4 + /* zip64 end of central dir locator                               */
+		0 + /* signature                       4 bytes  (0x07064b50)          */
+		4 + /* number of the disk with the                                    */
+		0 + /* start of the zip64 end of                                      */
+		0 + /* central directory               4 bytes                        */
+		8 + /* relative offset of the zip64                                   */
+		0 + /* end of central directory record 8 bytes                        */
+		4 /*   total number of disks           4 bytes                        */
+}
+
 type LocalFileRecord struct {
 	LocalFileHeader LocalFileHeader
 	FileData        FileData        `serial:"len=LocalFileHeader.CompressedSize,stream"`
@@ -161,7 +318,9 @@ type CentralDirectoryRecord struct {
 }
 
 type FileFormat struct {
-	LocalFileRecords            []LocalFileRecord `serial:"prefix='PK\x03\x04'"`
-	CentralDirectoryRecord      CentralDirectoryRecord
-	EndOfCentralDirectoryRecord EndOfCentralDirectoryRecord
+	LocalFileRecords                  []LocalFileRecord `serial:"prefix='PK\x03\x04'"`
+	CentralDirectoryRecord            CentralDirectoryRecord
+	Zip64EndOfCentralDirectoryRecord  *Zip64EndOfCentralDirectoryRecord  `serial:"prefix='PK\x06\x06'"`
+	Zip64EndOfCentralDirectoryLocator *Zip64EndOfCentralDirectoryLocator `serial:"prefix='PK\x06\x07'"`
+	EndOfCentralDirectoryRecord       EndOfCentralDirectoryRecord
 }
@@ -0,0 +1,31 @@
+package zipfile
+
+import (
+	"go-zipfile/zipfile/legacy"
+	"io"
+)
+
+func init() {
+	RegisterHeaderDecompressor(CompressionMethodShrunk, func(r io.Reader, header *FileHeader) io.ReadCloser {
+		return legacy.NewShrinkReader(r, int64(header.UncompressedSize))
+	})
+
+	reduced := []uint16{
+		CompressionMethodReducedWithCompressionFactor1,
+		CompressionMethodReducedWithCompressionFactor2,
+		CompressionMethodReducedWithCompressionFactor3,
+		CompressionMethodReducedWithCompressionFactor4,
+	}
+	for i, method := range reduced {
+		factor := i + 1
+		RegisterHeaderDecompressor(method, func(r io.Reader, header *FileHeader) io.ReadCloser {
+			return legacy.NewReduceReader(r, int64(header.UncompressedSize), factor)
+		})
+	}
+
+	// bit 1 selects the 8 KiB dictionary, bit 2 the tree for literals
+	RegisterHeaderDecompressor(CompressionMethodImploded, func(r io.Reader, header *FileHeader) io.ReadCloser {
+		return legacy.NewImplodeReader(r, int64(header.UncompressedSize),
+			header.Flags&CompressionOption1 != 0, header.Flags&CompressionOption2 != 0)
+	})
+}
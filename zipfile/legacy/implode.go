@@ -0,0 +1,193 @@
+package legacy
+
+import "io"
+
+const shannonFanoMaxBits = 16
+
+// shannonFano decodes the Shannon-Fano codes of Implode. Their bits are the
+// complement of the canonical Huffman codes of the same lengths, read least
+// significant first, so the table is indexed by the complemented input.
+type shannonFano struct {
+	table []uint32
+	bits  uint
+}
+
+// readShannonFano reads a tree of n code lengths, stored as a byte count
+// followed by bytes holding a length and how many codes in a row have it.
+func readShannonFano(br *bitReader, n int) (*shannonFano, error) {
+	count, err := br.readBits(8)
+	if err != nil {
+		return nil, err
+	}
+
+	lengths := make([]uint8, 0, n)
+	for range count + 1 {
+		b, err := br.readBits(8)
+		if err != nil {
+			return nil, err
+		}
+		for range b>>4 + 1 {
+			lengths = append(lengths, uint8(b&15)+1)
+		}
+	}
+	if len(lengths) != n {
+		return nil, ErrCorrupt
+	}
+
+	var counts [shannonFanoMaxBits + 1]int
+	bits := uint8(0)
+	for _, length := range lengths {
+		counts[length]++
+		bits = max(bits, length)
+	}
+	left := 1
+	for length := 1; length <= shannonFanoMaxBits; length++ {
+		left = left<<1 - counts[length]
+		if left < 0 {
+			return nil, ErrCorrupt
+		}
+	}
+
+	var next [shannonFanoMaxBits + 1]int
+	code := 0
+	for length := 1; length <= shannonFanoMaxBits; length++ {
+		code = (code + counts[length-1]) << 1
+		next[length] = code
+	}
+
+	sf := &shannonFano{table: make([]uint32, 1<<bits), bits: uint(bits)}
+	for symbol, length := range lengths {
+		code := next[length]
+		next[length]++
+
+		reversed := 0
+		for i := uint8(0); i < length; i++ {
+			reversed = reversed<<1 | code>>i&1
+		}
+		for i := reversed; i < len(sf.table); i += 1 << length {
+			sf.table[i] = uint32(symbol)<<8 | uint32(length)
+		}
+	}
+	return sf, nil
+}
+
+func (sf *shannonFano) decode(br *bitReader) (int, error) {
+	bits, available, err := br.peekBits(sf.bits)
+	if err != nil {
+		return 0, err
+	}
+	entry := sf.table[^bits&(1<<sf.bits-1)]
+	length := uint(entry & 0xff)
+	if length == 0 {
+		return 0, ErrCorrupt
+	}
+	if length > available {
+		return 0, io.ErrUnexpectedEOF
+	}
+	br.skipBits(length)
+	return int(entry >> 8), nil
+}
+
+// implodeReader decodes Implode, whose matches and optionally literals are
+// coded with Shannon-Fano trees stored at the start of the data.
+type implodeReader struct {
+	bits         bitReader
+	literalTree  bool
+	distanceBits uint
+	literals     *shannonFano
+	lengths      *shannonFano
+	distances    *shannonFano
+	window       window
+}
+
+// NewImplodeReader returns a reader decompressing size bytes of imploded
+// data. The 8 KiB dictionary and the literal tree are chosen by general
+// purpose bits 1 and 2 of the entry.
+func NewImplodeReader(r io.Reader, size int64, largeDictionary, literalTree bool) io.ReadCloser {
+	s := &implodeReader{
+		bits:         newBitReader(r),
+		literalTree:  literalTree,
+		distanceBits: 6,
+		window:       newWindow(4096),
+	}
+	if largeDictionary {
+		s.distanceBits = 7
+		s.window = newWindow(8192)
+	}
+
+	zr := &reader{remaining: size}
+	zr.step = func() error { return s.step(zr) }
+	return zr
+}
+
+func (s *implodeReader) readTrees() (err error) {
+	if s.literalTree {
+		if s.literals, err = readShannonFano(&s.bits, 256); err != nil {
+			return
+		}
+	}
+	if s.lengths, err = readShannonFano(&s.bits, 64); err != nil {
+		return
+	}
+	s.distances, err = readShannonFano(&s.bits, 64)
+	return
+}
+
+func (s *implodeReader) step(zr *reader) (err error) {
+	if s.distances == nil {
+		return s.readTrees()
+	}
+
+	zr.pending = zr.pending[:0]
+	literal, err := s.bits.readBits(1)
+	if err != nil {
+		return
+	}
+
+	if literal == 1 {
+		var b int
+		if s.literalTree {
+			b, err = s.literals.decode(&s.bits)
+		} else {
+			var raw uint32
+			raw, err = s.bits.readBits(8)
+			b = int(raw)
+		}
+		if err != nil {
+			return
+		}
+		zr.pending = s.window.write(zr.pending, byte(b))
+		return
+	}
+
+	low, err := s.bits.readBits(s.distanceBits)
+	if err != nil {
+		return
+	}
+	high, err := s.distances.decode(&s.bits)
+	if err != nil {
+		return
+	}
+	distance := (high<<s.distanceBits | int(low)) + 1
+
+	length, err := s.lengths.decode(&s.bits)
+	if err != nil {
+		return
+	}
+	if length == 63 {
+		var extra uint32
+		if extra, err = s.bits.readBits(8); err != nil {
+			return
+		}
+		length += int(extra)
+	}
+	// matches are shorter when literals are not worth a tree
+	if s.literalTree {
+		length += 3
+	} else {
+		length += 2
+	}
+
+	zr.pending, err = s.window.copy(zr.pending, distance, length)
+	return
+}
@@ -0,0 +1,128 @@
+// Package legacy implements the decompressors of the methods PKZIP 1.x used
+// before deflate: Shrink, Reduce and Implode. None of their streams marks its
+// end, so the decompressors stop after the uncompressed size of the entry.
+package legacy
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+var ErrCorrupt = errors.New("zip: corrupt legacy compressed data")
+
+// bitReader reads bit fields least significant bit first.
+type bitReader struct {
+	r     *bufio.Reader
+	bits  uint32
+	nbits uint
+}
+
+func newBitReader(r io.Reader) bitReader {
+	return bitReader{r: bufio.NewReader(r)}
+}
+
+// readBits reads up to 16 bits.
+func (br *bitReader) readBits(n uint) (uint32, error) {
+	for br.nbits < n {
+		b, err := br.r.ReadByte()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, err
+		}
+		br.bits |= uint32(b) << br.nbits
+		br.nbits += 8
+	}
+	value := br.bits & (1<<n - 1)
+	br.bits >>= n
+	br.nbits -= n
+	return value, nil
+}
+
+// peekBits returns the next n bits, up to 16, padded with zeros past the end
+// of the input, and how many of them are real.
+func (br *bitReader) peekBits(n uint) (uint32, uint, error) {
+	for br.nbits < n {
+		b, err := br.r.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, 0, err
+		}
+		br.bits |= uint32(b) << br.nbits
+		br.nbits += 8
+	}
+	return br.bits & (1<<n - 1), br.nbits, nil
+}
+
+func (br *bitReader) skipBits(n uint) {
+	br.bits >>= n
+	br.nbits -= n
+}
+
+// reader hands out the bytes that a decompressor produces one step at a time.
+type reader struct {
+	remaining int64
+	pending   []byte
+	step      func() error
+	err       error
+}
+
+func (r *reader) Read(p []byte) (n int, err error) {
+	for len(r.pending) == 0 && r.remaining > 0 && r.err == nil {
+		r.err = r.step()
+		if int64(len(r.pending)) > r.remaining {
+			r.pending = r.pending[:r.remaining]
+		}
+	}
+
+	if len(r.pending) == 0 {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+		return 0, r.err
+	}
+
+	n = copy(p, r.pending)
+	r.pending = r.pending[n:]
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+func (r *reader) Close() error {
+	r.remaining = 0
+	r.pending = nil
+	return nil
+}
+
+// window keeps the last bytes written for the matches of Reduce and Implode,
+// which may reach before the start of the output, where it reads as zeros.
+type window struct {
+	buf      []byte
+	position int
+}
+
+func newWindow(size int) window {
+	return window{buf: make([]byte, size)}
+}
+
+func (w *window) write(pending []byte, b byte) []byte {
+	w.buf[w.position%len(w.buf)] = b
+	w.position++
+	return append(pending, b)
+}
+
+func (w *window) copy(pending []byte, distance, length int) ([]byte, error) {
+	if distance <= 0 || distance > len(w.buf) {
+		return pending, ErrCorrupt
+	}
+	for range length {
+		var b byte
+		if distance <= w.position {
+			b = w.buf[(w.position-distance)%len(w.buf)]
+		}
+		pending = w.write(pending, b)
+	}
+	return pending, nil
+}
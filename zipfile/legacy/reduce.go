@@ -0,0 +1,136 @@
+package legacy
+
+import "io"
+
+const reduceDLE = 144
+
+// reduceReader decodes Reduce, whose bytes are first predicted from follower
+// sets of the previous byte, then expanded by a run-length coding of matches
+// introduced by the byte 144.
+type reduceReader struct {
+	bits      bitReader
+	factor    uint
+	followers [256][]byte
+	last      byte
+	window    window
+	loaded    bool
+}
+
+// NewReduceReader returns a reader decompressing size bytes of data reduced
+// with the given compression factor, 1 to 4.
+func NewReduceReader(r io.Reader, size int64, factor int) io.ReadCloser {
+	s := &reduceReader{
+		bits:   newBitReader(r),
+		factor: uint(factor),
+		window: newWindow(4096),
+	}
+
+	zr := &reader{remaining: size}
+	zr.step = func() error { return s.step(zr) }
+	if factor < 1 || factor > 4 {
+		zr.step = func() error { return ErrCorrupt }
+	}
+	return zr
+}
+
+// readFollowerSets reads the follower sets, from the one of byte 255 down.
+func (s *reduceReader) readFollowerSets() error {
+	for i := 255; i >= 0; i-- {
+		count, err := s.bits.readBits(6)
+		if err != nil {
+			return err
+		}
+		if count > 32 {
+			return ErrCorrupt
+		}
+		s.followers[i] = make([]byte, count)
+		for j := range s.followers[i] {
+			b, err := s.bits.readBits(8)
+			if err != nil {
+				return err
+			}
+			s.followers[i][j] = byte(b)
+		}
+	}
+	return nil
+}
+
+// readByte predicts the next byte from the follower set of the previous one.
+func (s *reduceReader) readByte() (byte, error) {
+	followers := s.followers[s.last]
+	if len(followers) > 0 {
+		literal, err := s.bits.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		if literal == 0 {
+			// enough bits to index the set, at least one
+			n := uint(1)
+			for 1<<n < len(followers) {
+				n++
+			}
+			index, err := s.bits.readBits(n)
+			if err != nil {
+				return 0, err
+			}
+			if int(index) >= len(followers) {
+				return 0, ErrCorrupt
+			}
+			s.last = followers[index]
+			return s.last, nil
+		}
+	}
+
+	b, err := s.bits.readBits(8)
+	if err != nil {
+		return 0, err
+	}
+	s.last = byte(b)
+	return s.last, nil
+}
+
+func (s *reduceReader) step(zr *reader) (err error) {
+	if !s.loaded {
+		s.loaded = true
+		return s.readFollowerSets()
+	}
+
+	zr.pending = zr.pending[:0]
+	c, err := s.readByte()
+	if err != nil {
+		return
+	}
+	if c != reduceDLE {
+		zr.pending = s.window.write(zr.pending, c)
+		return
+	}
+
+	v, err := s.readByte()
+	if err != nil {
+		return
+	}
+	if v == 0 {
+		zr.pending = s.window.write(zr.pending, reduceDLE)
+		return
+	}
+
+	// the low bits of v hold the length, all set when a byte extends it
+	mask := byte(0xff) >> s.factor
+	length := int(v & mask)
+	if v&mask == mask {
+		var extra byte
+		if extra, err = s.readByte(); err != nil {
+			return
+		}
+		length += int(extra)
+	}
+
+	low, err := s.readByte()
+	if err != nil {
+		return
+	}
+	distance := int(v>>(8-s.factor))<<8 + int(low) + 1
+
+	zr.pending, err = s.window.copy(zr.pending, distance, length+3)
+	return
+}
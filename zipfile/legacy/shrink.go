@@ -0,0 +1,137 @@
+package legacy
+
+import "io"
+
+const (
+	shrinkMinBits   = 9
+	shrinkMaxBits   = 13
+	shrinkTableSize = 1 << shrinkMaxBits
+	shrinkControl   = 256
+	shrinkFirstFree = 257
+	shrinkFree      = -1
+	shrinkRoot      = -2
+)
+
+// shrinkReader decodes Shrink, the LZW of PKZIP 1.x. Codes grow from 9 to 13
+// bits only when the stream says so, and partial clearing frees the codes of
+// the table that are not the prefix of another code.
+type shrinkReader struct {
+	bits     bitReader
+	codeSize uint
+	parent   [shrinkTableSize]int16
+	value    [shrinkTableSize]byte
+	lastFree int
+	previous int
+	stack    []byte
+}
+
+// NewShrinkReader returns a reader decompressing size bytes of Shrink data.
+func NewShrinkReader(r io.Reader, size int64) io.ReadCloser {
+	s := &shrinkReader{
+		bits:     newBitReader(r),
+		codeSize: shrinkMinBits,
+		lastFree: shrinkControl,
+		previous: -1,
+	}
+	for code := range s.parent {
+		if code < shrinkControl {
+			s.parent[code] = shrinkRoot
+			s.value[code] = byte(code)
+		} else {
+			s.parent[code] = shrinkFree
+		}
+	}
+
+	zr := &reader{remaining: size}
+	zr.step = func() error { return s.step(zr) }
+	return zr
+}
+
+func (s *shrinkReader) step(zr *reader) error {
+	code, err := s.bits.readBits(s.codeSize)
+	if err != nil {
+		return err
+	}
+
+	if code == shrinkControl {
+		if code, err = s.bits.readBits(s.codeSize); err != nil {
+			return err
+		}
+		switch code {
+		case 1:
+			if s.codeSize == shrinkMaxBits {
+				return ErrCorrupt
+			}
+			s.codeSize++
+		case 2:
+			s.partialClear()
+		default:
+			return ErrCorrupt
+		}
+		return nil
+	}
+
+	if s.previous < 0 {
+		if code >= shrinkControl {
+			return ErrCorrupt
+		}
+		s.previous = int(code)
+		zr.pending = append(zr.pending[:0], byte(code))
+		return nil
+	}
+
+	// a code not in the table yet can only be the one about to be added,
+	// which repeats the previous string followed by its first byte
+	current := int(code)
+	unknown := s.parent[current] == shrinkFree
+	if unknown {
+		current = s.previous
+	}
+
+	s.stack = s.stack[:0]
+	for c := current; c != shrinkRoot; c = int(s.parent[c]) {
+		if c < 0 || len(s.stack) >= shrinkTableSize {
+			return ErrCorrupt
+		}
+		s.stack = append(s.stack, s.value[c])
+	}
+	first := s.stack[len(s.stack)-1]
+	if unknown {
+		s.stack = append([]byte{first}, s.stack...)
+	}
+
+	zr.pending = zr.pending[:0]
+	for i := len(s.stack) - 1; i >= 0; i-- {
+		zr.pending = append(zr.pending, s.stack[i])
+	}
+
+	// the new code takes the lowest free place, none once the table is full
+	free := s.lastFree + 1
+	for free < shrinkTableSize && s.parent[free] != shrinkFree {
+		free++
+	}
+	if free < shrinkTableSize {
+		s.lastFree = free
+		s.parent[free] = int16(s.previous)
+		s.value[free] = first
+	}
+
+	s.previous = int(code)
+	return nil
+}
+
+// partialClear frees every code that is no other code's prefix.
+func (s *shrinkReader) partialClear() {
+	var hasChild [shrinkTableSize]bool
+	for code := shrinkFirstFree; code < shrinkTableSize; code++ {
+		if parent := s.parent[code]; parent > shrinkControl {
+			hasChild[parent] = true
+		}
+	}
+	for code := shrinkFirstFree; code < shrinkTableSize; code++ {
+		if !hasChild[code] {
+			s.parent[code] = shrinkFree
+		}
+	}
+	s.lastFree = shrinkControl
+}
@@ -0,0 +1,105 @@
+package zipfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// LZMA entries start with the version of the LZMA SDK that wrote them and the
+// size of the properties, which replace the header of the .lzma format.
+var lzmaVersion = [2]byte{9, 20}
+
+const lzmaPropertiesSize = 5
+
+var errLZMAHeader = errors.New("zip: invalid LZMA header")
+
+// lzmaDictCap returns the size of the dictionary for the levels of the xz tool,
+// 0 to 9, or for level 6 if out of range.
+func lzmaDictCap(level int) int {
+	if level < 0 || level > 9 {
+		level = 6
+	}
+	dictCaps := [...]int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+	return dictCaps[level]
+}
+
+func init() {
+	RegisterCompressor(CompressionMethodLZMA, newLZMAWriter)
+	RegisterHeaderDecompressor(CompressionMethodLZMA, newLZMAReader)
+}
+
+// newLZMAWriter takes the levels of the xz tool, 0 to 9, which set the size of
+// the dictionary. Other levels select 6. The stream ends with an end of stream
+// marker since its size is not known beforehand, as CompressionOption1 tells.
+func newLZMAWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	header := &lzmaHeaderWriter{w: w}
+	lw, err := lzma.WriterConfig{DictCap: lzmaDictCap(level), EOSMarker: true}.NewWriter(header)
+	if err != nil {
+		return nil, err
+	}
+	return lw, nil
+}
+
+// lzmaHeaderWriter turns the header of the .lzma format into the one of ZIP
+// entries by dropping the uncompressed size that follows the properties.
+type lzmaHeaderWriter struct {
+	w       io.Writer
+	written int
+}
+
+func (w *lzmaHeaderWriter) Write(b []byte) (n int, err error) {
+	if w.written >= lzma.HeaderLen {
+		n, err = w.w.Write(b)
+		w.written += n
+		return
+	}
+
+	var buf []byte
+	if w.written == 0 {
+		buf = append(buf, lzmaVersion[:]...)
+		buf = binary.LittleEndian.AppendUint16(buf, lzmaPropertiesSize)
+	}
+	for _, c := range b {
+		if w.written < lzmaPropertiesSize || w.written >= lzma.HeaderLen {
+			buf = append(buf, c)
+		}
+		w.written++
+	}
+	if _, err = w.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// newLZMAReader rebuilds the header of the .lzma format, whose size is unknown
+// when the stream ends with an end of stream marker.
+func newLZMAReader(r io.Reader, header *FileHeader) io.ReadCloser {
+	prefix := make([]byte, 4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return errorReadCloser{errLZMAHeader}
+	}
+	propertiesSize := binary.LittleEndian.Uint16(prefix[2:])
+	if propertiesSize != lzmaPropertiesSize {
+		return errorReadCloser{errLZMAHeader}
+	}
+
+	classic := make([]byte, lzma.HeaderLen)
+	if _, err := io.ReadFull(r, classic[:lzmaPropertiesSize]); err != nil {
+		return errorReadCloser{errLZMAHeader}
+	}
+	size := int64(-1)
+	if header.Flags&CompressionOption1 == 0 {
+		size = int64(header.UncompressedSize)
+	}
+	binary.LittleEndian.PutUint64(classic[lzmaPropertiesSize:], uint64(size))
+
+	lr, err := lzma.NewReader(io.MultiReader(bytes.NewReader(classic), r))
+	if err != nil {
+		return errorReadCloser{err}
+	}
+	return io.NopCloser(lr)
+}
@@ -0,0 +1,92 @@
+package zipfile
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// fixture is an entry of an archive in testdata, with the method and the
+// flags it is expected to be compressed with.
+type fixture struct {
+	name   string
+	method uint16
+	flags  uint16
+}
+
+// testFixtures decompresses the entries of an archive in testdata, which Open
+// checks against their CRC-32.
+func testFixtures(t *testing.T, archive string, fixtures ...fixture) {
+	t.Helper()
+	zr, err := Open(filepath.Join("testdata", archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	if len(zr.Entries) != len(fixtures) {
+		t.Fatalf("%s: %d entries, want %d", archive, len(zr.Entries), len(fixtures))
+	}
+	for i, e := range zr.Entries {
+		want := fixtures[i]
+		if e.Name != want.name || e.CompressionMethod != want.method || e.Flags != want.flags {
+			t.Fatalf("%s: entry %q method %d flags %#x, want %q method %d flags %#x",
+				archive, e.Name, e.CompressionMethod, e.Flags, want.name, want.method, want.flags)
+		}
+
+		rc, err := e.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", e.Name, err)
+		}
+		n, err := io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			t.Errorf("%s: %v", e.Name, err)
+		} else if uint64(n) != e.UncompressedSize {
+			t.Errorf("%s: %d bytes, want %d", e.Name, n, e.UncompressedSize)
+		}
+	}
+}
+
+// The fixtures decompress with Info-ZIP unzip, except Reduce, which it does not
+// support, and PPMd, which it does not either but libarchive does.
+
+func TestShrink(t *testing.T) {
+	// long enough to grow the codes to 13 bits and clear the table
+	testFixtures(t, "shrink.zip", fixture{"shrink.txt", CompressionMethodShrunk, 0})
+}
+
+func TestReduce(t *testing.T) {
+	testFixtures(t, "reduce.zip",
+		fixture{"reduce1.txt", CompressionMethodReducedWithCompressionFactor1, 0},
+		fixture{"reduce2.txt", CompressionMethodReducedWithCompressionFactor2, 0},
+		fixture{"reduce3.txt", CompressionMethodReducedWithCompressionFactor3, 0},
+		fixture{"reduce4.txt", CompressionMethodReducedWithCompressionFactor4, 0},
+	)
+}
+
+func TestImplode(t *testing.T) {
+	testFixtures(t, "implode.zip",
+		fixture{"implode4k.txt", CompressionMethodImploded, 0},
+		fixture{"implode8k.txt", CompressionMethodImploded, CompressionOption1},
+		fixture{"implode4k-literals.txt", CompressionMethodImploded, CompressionOption2},
+		fixture{"implode8k-literals.txt", CompressionMethodImploded, CompressionOption1 | CompressionOption2},
+	)
+}
+
+func TestDeflate64(t *testing.T) {
+	// a stored and a fixed block with a 60000-byte match and distances past
+	// 32 KiB, then dynamic blocks
+	testFixtures(t, "deflate64.zip",
+		fixture{"fixed.txt", CompressionMethodDeflate64, 0},
+		fixture{"dynamic.txt", CompressionMethodDeflate64, 0},
+	)
+}
+
+func TestPPMd(t *testing.T) {
+	// a model of 1 MiB that runs out of memory, restarted or cut off
+	testFixtures(t, "ppmd.zip",
+		fixture{"restart.txt", CompressionMethodPPMd, 0},
+		fixture{"cutoff.txt", CompressionMethodPPMd, 0},
+	)
+}
@@ -0,0 +1,312 @@
+package zipfile
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// DefaultMemoryBudget bounds the entry data held in memory while Marshal
+// compresses in parallel.
+const DefaultMemoryBudget = 256 << 20
+
+const (
+	// deflated entries larger than a chunk are split, each chunk compressed
+	// on its own with the window before it as dictionary
+	parallelChunkSize = 1 << 20
+	deflateWindowSize = 32 << 10
+)
+
+// SetWorkers sets how many entries, or chunks of large deflated entries,
+// Marshal compresses at once, all processors for n <= 0. The default of 1
+// compresses each entry while it is written. With more workers, the archive
+// is the same whatever their number, but large deflated entries differ from
+// the ones written by a single worker, being made of independently compressed
+// chunks joined at sync flush points.
+func (z *Zip) SetWorkers(n int) {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	z.Workers = n
+}
+
+// SetEncoderConcurrency sets how many goroutines encode each entry, one by
+// default, or all processors for n <= 0, for the methods whose encoder can
+// use several, such as Zstandard. Each worker encodes with as many.
+func (z *Zip) SetEncoderConcurrency(n int) {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	z.EncoderConcurrency = n
+}
+
+// SetMemoryBudget bounds the bytes of entry data, before and after
+// compression, held in memory while compressing in parallel. Entries that
+// exceed it and cannot be split are compressed while written instead, which
+// holds back the workers for as long. A budget of 0 or less selects
+// DefaultMemoryBudget.
+func (z *Zip) SetMemoryBudget(bytes int64) {
+	z.MemoryBudget = bytes
+}
+
+// compressTask is an entry, or a chunk of one, that a worker compresses.
+// Inline tasks are whole entries left to the writer.
+type compressTask struct {
+	entry   *FileEntry
+	first   bool
+	last    bool
+	inline  bool
+	chunked bool
+	offset  int64
+	length  int64
+	cost    int64
+	input   []byte
+	output  []byte
+	err     error
+	done    chan struct{}
+}
+
+// planTasks splits an entry into the tasks that compress it.
+func planTasks(entry *FileEntry, budget int64) []*compressTask {
+	length := entry.Data.Len()
+	whole := &compressTask{entry: entry, first: true, last: true, length: length, cost: 2 * length}
+	switch {
+	case entry.CompressionMethod == CompressionMethodStored || length == 0:
+		// nothing to gain from a worker
+		whole.inline = true
+	case entry.CompressionMethod == CompressionMethodDeflated && length > parallelChunkSize:
+		var tasks []*compressTask
+		for offset := int64(0); offset < length; offset += parallelChunkSize {
+			size := min(parallelChunkSize, length-offset)
+			tasks = append(tasks, &compressTask{
+				entry:   entry,
+				first:   offset == 0,
+				last:    offset+size == length,
+				chunked: true,
+				offset:  offset,
+				length:  size,
+				cost:    2*size + deflateWindowSize,
+			})
+		}
+		return tasks
+	case whole.cost > budget:
+		whole.inline = true
+	}
+	return []*compressTask{whole}
+}
+
+func (t *compressTask) run(concurrency int) {
+	defer close(t.done)
+
+	// a chunk reads the end of the previous one as dictionary
+	dictionary := int64(0)
+	if t.chunked {
+		dictionary = min(t.offset, deflateWindowSize)
+	}
+	src, err := t.entry.Data.section(t.offset-dictionary, dictionary+t.length).Open()
+	if err != nil {
+		t.err = err
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	data := make([]byte, dictionary+t.length)
+	n, err := io.ReadFull(src, data)
+	if err == io.ErrUnexpectedEOF && !t.chunked {
+		// the whole entry is what its source holds now
+		data, err = data[:n], nil
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		t.err = err
+		return
+	}
+	t.input = data[dictionary:]
+
+	var output bytes.Buffer
+	if t.chunked {
+		var fw *flate.Writer
+		if fw, t.err = flate.NewWriterDict(&output, t.entry.CompressionLevel, data[:dictionary]); t.err != nil {
+			return
+		}
+		_, _ = fw.Write(t.input)
+		if t.last {
+			t.err = fw.Close()
+		} else {
+			// a sync flush ends the chunk on a byte boundary without ending
+			// the stream
+			t.err = fw.Flush()
+		}
+	} else {
+		var compress OptionsCompressor
+		if compress, t.err = compressor(t.entry.CompressionMethod); t.err != nil {
+			return
+		}
+		var cw io.WriteCloser
+		options := CompressorOptions{Level: t.entry.CompressionLevel, Concurrency: concurrency}
+		if cw, t.err = compress(&output, options); t.err != nil {
+			return
+		}
+		if _, t.err = cw.Write(t.input); t.err != nil {
+			return
+		}
+		t.err = cw.Close()
+	}
+	t.output = output.Bytes()
+}
+
+// budget is a counting semaphore of bytes.
+type budget struct {
+	mu      sync.Mutex
+	cond    sync.Cond
+	size    int64
+	free    int64
+	aborted bool
+}
+
+func newBudget(size int64) *budget {
+	if size <= 0 {
+		size = DefaultMemoryBudget
+	}
+	b := &budget{size: size, free: size}
+	b.cond.L = &b.mu
+	return b
+}
+
+// acquire waits for n bytes, or the whole budget if larger, and reports
+// false once aborted.
+func (b *budget) acquire(n int64) bool {
+	n = min(n, b.size)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.free < n && !b.aborted {
+		b.cond.Wait()
+	}
+	b.free -= n
+	return !b.aborted
+}
+
+func (b *budget) release(n int64) {
+	n = min(n, b.size)
+	b.mu.Lock()
+	b.free += n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+func (b *budget) abort() {
+	b.mu.Lock()
+	b.aborted = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// writeParallel writes the entries in order while workers compress those
+// ahead, within the memory budget. Encryption is applied as the compressed
+// data is written.
+func (m *marshaller) writeParallel() (err error) {
+	z := m.zip
+	memory := newBudget(z.MemoryBudget)
+	tasks := make(chan *compressTask)
+	ordered := make(chan *compressTask, z.Workers)
+	stop := make(chan struct{})
+
+	var workers sync.WaitGroup
+	for range z.Workers {
+		workers.Go(func() {
+			for t := range tasks {
+				t.run(z.EncoderConcurrency)
+			}
+		})
+	}
+
+	go func() {
+		defer close(ordered)
+		defer close(tasks)
+		for _, entry := range z.FileEntries {
+			for _, t := range planTasks(entry, memory.size) {
+				t.done = make(chan struct{})
+				if !t.inline && !memory.acquire(t.cost) {
+					return
+				}
+				select {
+				case ordered <- t:
+				case <-stop:
+					return
+				}
+				if t.inline {
+					continue
+				}
+				select {
+				case tasks <- t:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+
+	defer func() {
+		close(stop)
+		memory.abort()
+		for range ordered {
+		}
+		workers.Wait()
+	}()
+
+	var fh *FileHeader
+	var fw *fileWriter
+	var zip64 bool
+	for t := range ordered {
+		if t.inline {
+			if err = m.writeEntry(t.entry); err != nil {
+				return
+			}
+			continue
+		}
+
+		<-t.done
+		if err = t.err; err != nil {
+			return
+		}
+		output := t.output
+		if t.first && t.last && z.Policy != nil && !z.Policy.worthwhile(int64(len(output)), int64(len(t.input))) {
+			// known before anything is written
+			t.entry.CompressionMethod = CompressionMethodStored
+			output = t.input
+		}
+		if t.first {
+			if fh, zip64, err = m.begin(t.entry); err != nil {
+				return
+			}
+			if fw, err = newRawFileWriter(m.writer, fh, []byte(z.Password)); err != nil {
+				return
+			}
+		}
+		if err = fw.writeCompressed(output, t.input); err != nil {
+			return
+		}
+		t.input, t.output = nil, nil
+		memory.release(t.cost)
+
+		if t.last {
+			if err = fw.close(); err != nil {
+				return
+			}
+			t.entry.update(fh)
+			if !m.worthwhile(t.entry, fw.payload.count) {
+				err = m.rewriteStored(t.entry)
+			} else {
+				err = m.finish(t.entry, fh, zip64)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,180 @@
+package zipfile
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"strings"
+)
+
+// CompressionPolicy decides which entries are worth compressing. Entries it
+// turns down are stored instead.
+type CompressionPolicy struct {
+	// StoredExtensions holds the extensions of files stored without trying,
+	// in lower case with their dot.
+	StoredExtensions map[string]bool
+	// Sniff stores files whose first bytes identify a compressed format.
+	Sniff bool
+	// SampleSize is how many bytes from the start of a file are compressed
+	// when it is added, to estimate the saving, none if 0.
+	SampleSize int64
+	// MinSaving is the fraction of its size that compressing an entry has
+	// to save, on the sample and on the whole data. Even at 0, an entry that
+	// does not get smaller is stored.
+	MinSaving float64
+}
+
+// DefaultStoredExtensions are formats that are compressed already.
+var DefaultStoredExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".heic": true, ".avif": true,
+	".mp3": true, ".m4a": true, ".aac": true, ".ogg": true, ".opus": true, ".flac": true,
+	".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true,
+	".zip": true, ".jar": true, ".apk": true, ".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".epub": true,
+	".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".txz": true, ".zst": true, ".lz4": true, ".br": true,
+	".7z": true, ".rar": true, ".woff": true, ".woff2": true,
+}
+
+// compressedSignatures are the first bytes of compressed formats, where
+// a zero byte in the mask matches anything.
+var compressedSignatures = []struct {
+	magic []byte
+	mask  []byte
+}{
+	{magic: []byte{0xff, 0xd8, 0xff}},                            // JPEG
+	{magic: []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}}, // PNG
+	{magic: []byte("GIF8")},                                      // GIF
+	{magic: []byte("RIFF\x00\x00\x00\x00WEBP"), mask: []byte("\xff\xff\xff\xff\x00\x00\x00\x00\xff\xff\xff\xff")},
+	{magic: []byte("\x00\x00\x00\x00ftyp"), mask: []byte("\x00\x00\x00\x00\xff\xff\xff\xff")}, // MP4, MOV, HEIC, AVIF
+	{magic: []byte{0x1a, 0x45, 0xdf, 0xa3}},           // Matroska, WebM
+	{magic: []byte("ID3")},                            // MP3
+	{magic: []byte("OggS")},                           // Ogg
+	{magic: []byte("fLaC")},                           // FLAC
+	{magic: []byte("PK\x03\x04")},                     // ZIP
+	{magic: []byte{0x1f, 0x8b}},                       // gzip
+	{magic: []byte("BZh")},                            // bzip2
+	{magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},   // xz
+	{magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},           // zstd
+	{magic: []byte{0x04, 0x22, 0x4d, 0x18}},           // LZ4
+	{magic: []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}}, // 7z
+	{magic: []byte("Rar!\x1a\x07")},                   // RAR
+	{magic: []byte("wOFF")},                           // WOFF
+	{magic: []byte("wOF2")},                           // WOFF2
+}
+
+const sniffSize = 16
+
+// NewCompressionPolicy returns a policy storing the DefaultStoredExtensions,
+// sniffing contents and compressing a sample of 64 KiB, which has to save 2%.
+func NewCompressionPolicy() *CompressionPolicy {
+	extensions := make(map[string]bool, len(DefaultStoredExtensions))
+	for extension := range DefaultStoredExtensions {
+		extensions[extension] = true
+	}
+	return &CompressionPolicy{
+		StoredExtensions: extensions,
+		Sniff:            true,
+		SampleSize:       64 << 10,
+		MinSaving:        0.02,
+	}
+}
+
+// SetCompressionPolicy sets the policy entries are stored by when compressing
+// them does not pay off. The default only stores entries whose compressed
+// data turns out no smaller, and nil keeps whatever compressors produce.
+// Extensions, sniffing and samples are applied by Add, the saving on the whole
+// data by Marshal.
+func (z *Zip) SetCompressionPolicy(policy *CompressionPolicy) {
+	z.Policy = policy
+}
+
+// worthwhile tells whether size bytes compressed to compressed ones are kept.
+func (p *CompressionPolicy) worthwhile(compressed, size int64) bool {
+	return float64(compressed) < float64(size)*(1-p.MinSaving)
+}
+
+// apply stores the entry if its name, its first bytes or a sample of it show
+// that compressing would not pay off.
+func (p *CompressionPolicy) apply(entry *FileEntry) (err error) {
+	if entry.CompressionMethod == CompressionMethodStored {
+		return
+	}
+
+	if p.StoredExtensions[strings.ToLower(path.Ext(entry.FilePath))] {
+		entry.CompressionMethod = CompressionMethodStored
+		return
+	}
+
+	if !p.Sniff && p.SampleSize <= 0 {
+		return
+	}
+	sample, err := readSample(entry.Data, max(p.SampleSize, sniffSize))
+	if err != nil {
+		return
+	}
+	if p.Sniff && isCompressed(sample) {
+		entry.CompressionMethod = CompressionMethodStored
+		return
+	}
+
+	if p.SampleSize <= 0 {
+		return
+	}
+	sample = sample[:min(int64(len(sample)), p.SampleSize)]
+	compress, err := compressor(entry.CompressionMethod)
+	if err != nil {
+		return
+	}
+	counter := &countWriter{w: io.Discard}
+	cw, err := compress(counter, CompressorOptions{Level: entry.CompressionLevel})
+	if err != nil {
+		return
+	}
+	if _, err = cw.Write(sample); err != nil {
+		return
+	}
+	if err = cw.Close(); err != nil {
+		return
+	}
+	if !p.worthwhile(counter.count, int64(len(sample))) {
+		entry.CompressionMethod = CompressionMethodStored
+	}
+	return
+}
+
+func readSample(data FileData, size int64) (sample []byte, err error) {
+	src, err := data.Open()
+	if err != nil {
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	var buf bytes.Buffer
+	_, err = io.CopyN(&buf, src, size)
+	if err == io.EOF {
+		err = nil
+	}
+	return buf.Bytes(), err
+}
+
+func isCompressed(sample []byte) bool {
+	for _, signature := range compressedSignatures {
+		if len(sample) < len(signature.magic) {
+			continue
+		}
+		matches := true
+		for i, b := range signature.magic {
+			mask := byte(0xff)
+			if signature.mask != nil {
+				mask = signature.mask[i]
+			}
+			if sample[i]&mask != b&mask {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
@@ -21,4 +21,5 @@ const (
 	StatIsRegularFile             = 0100000
 	StatIsSymbolicLink            = 0120000
 	StatIsSocket                  = 0140000
+	StatFileTypeMask              = 0170000
 )
@@ -0,0 +1,19 @@
+package zipfile
+
+import (
+	"go-zipfile/zipfile/ppmd"
+	"io"
+)
+
+func init() {
+	RegisterCompressor(CompressionMethodPPMd, newPPMdWriter)
+	RegisterHeaderDecompressor(CompressionMethodPPMd, func(r io.Reader, header *FileHeader) io.ReadCloser {
+		return ppmd.NewReader(r, int64(header.UncompressedSize))
+	})
+}
+
+// newPPMdWriter takes levels 1 to 9, which set the model order, the memory
+// size and the restoration method as 7-Zip does. Other levels select 5.
+func newPPMdWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return ppmd.NewWriter(w, ppmd.LevelParameters(level))
+}
@@ -0,0 +1,1035 @@
+// Package ppmd implements PPMd variant I revision 1, the PPMd of ZIP archives,
+// after the public domain code of Dmitry Shkarin and Igor Pavlov.
+//
+// The model lives in a single heap addressed by 32-bit offsets, with the
+// memory layout of the original, since when and how memory runs out is part
+// of the format.
+package ppmd
+
+import "encoding/binary"
+
+const (
+	MinOrder = 2
+	MaxOrder = 16
+
+	RestoreRestart = 0
+	RestoreCutOff  = 1
+
+	maxFreq     = 124
+	unitSize    = 12
+	intBits     = 7
+	periodBits  = 7
+	binScale    = 1 << (intBits + periodBits)
+	numIndexes  = 4 + 4 + 4 + 26
+	emptyNode   = 0xffffffff
+	stateSize   = 6
+	maxOrderCap = 12
+)
+
+var (
+	expEscape  = [16]byte{25, 14, 9, 7, 5, 5, 4, 4, 4, 3, 3, 3, 2, 2, 2, 2}
+	initBinEsc = [8]uint16{0x3cdd, 0x1f3f, 0x59bf, 0x48f3, 0x64a1, 0x5abc, 0x6632, 0x6051}
+	indx2Units [numIndexes]byte
+	units2Indx [128]byte
+	ns2BSIndx  [256]byte
+	ns2Indx    [260]byte
+)
+
+func init() {
+	k := 0
+	for i := range numIndexes {
+		step := 4
+		if i < 12 {
+			step = i>>2 + 1
+		}
+		for ; step > 0; step-- {
+			units2Indx[k] = byte(i)
+			k++
+		}
+		indx2Units[i] = byte(k)
+	}
+
+	ns2BSIndx[0] = 0 << 1
+	ns2BSIndx[1] = 1 << 1
+	for i := 2; i < 11; i++ {
+		ns2BSIndx[i] = 2 << 1
+	}
+	for i := 11; i < 256; i++ {
+		ns2BSIndx[i] = 3 << 1
+	}
+
+	i := 0
+	for ; i < 5; i++ {
+		ns2Indx[i] = byte(i)
+	}
+	for m, k := i, 1; i < 260; i++ {
+		ns2Indx[i] = byte(m)
+		if k--; k == 0 {
+			m++
+			k = m - 4
+		}
+	}
+}
+
+type see struct {
+	summ  uint16
+	shift byte
+	count byte
+}
+
+func (s *see) update() {
+	if s.shift < periodBits {
+		if s.count--; s.count == 0 {
+			s.summ <<= 1
+			s.count = byte(3 << s.shift)
+			s.shift++
+		}
+	}
+}
+
+// model is the PPMd state. Contexts take 12 bytes: NumStats, Flags, SummFreq,
+// Stats and Suffix. States take 6: Symbol, Freq and Successor. A context with
+// a single state keeps it in place of SummFreq and Stats.
+type model struct {
+	heap        []byte
+	size        uint32
+	alignOffset uint32
+
+	minContext, maxContext uint32
+	foundState             uint32
+	orderFall              uint32
+	initEsc                uint32
+	prevSuccess            uint32
+	maxOrder               uint32
+	runLength, initRL      int32
+	restoreMethod          uint32
+
+	glueCount  uint32
+	loUnit     uint32
+	hiUnit     uint32
+	text       uint32
+	unitsStart uint32
+
+	freeList [numIndexes]uint32
+	stamps   [numIndexes]uint32
+
+	dummySee see
+	see      [24][32]see
+	binSumm  [25][64]uint16
+}
+
+func newModel(maxOrder, memorySize, restoreMethod uint32) *model {
+	p := &model{
+		size:          memorySize,
+		alignOffset:   4 - memorySize&3,
+		maxOrder:      maxOrder,
+		restoreMethod: restoreMethod,
+	}
+	p.heap = make([]byte, p.alignOffset+memorySize)
+	p.restartModel()
+	p.dummySee.shift = periodBits
+	p.dummySee.count = 64
+	return p
+}
+
+func (p *model) u16(offset uint32) uint16 {
+	return binary.LittleEndian.Uint16(p.heap[offset:])
+}
+
+func (p *model) setU16(offset uint32, v uint16) {
+	binary.LittleEndian.PutUint16(p.heap[offset:], v)
+}
+
+func (p *model) u32(offset uint32) uint32 {
+	return binary.LittleEndian.Uint32(p.heap[offset:])
+}
+
+func (p *model) setU32(offset uint32, v uint32) {
+	binary.LittleEndian.PutUint32(p.heap[offset:], v)
+}
+
+// context fields
+func (p *model) numStats(c uint32) uint32  { return uint32(p.heap[c]) }
+func (p *model) setNumStats(c, v uint32)   { p.heap[c] = byte(v) }
+func (p *model) flags(c uint32) uint32     { return uint32(p.heap[c+1]) }
+func (p *model) setFlags(c, v uint32)      { p.heap[c+1] = byte(v) }
+func (p *model) summFreq(c uint32) uint32  { return uint32(p.u16(c + 2)) }
+func (p *model) setSummFreq(c, v uint32)   { p.setU16(c+2, uint16(v)) }
+func (p *model) stats(c uint32) uint32     { return p.u32(c + 4) }
+func (p *model) setStats(c, v uint32)      { p.setU32(c+4, v) }
+func (p *model) suffix(c uint32) uint32    { return p.u32(c + 8) }
+func (p *model) setSuffix(c, v uint32)     { p.setU32(c+8, v) }
+func oneState(c uint32) uint32             { return c + 2 }
+func (p *model) symbol(s uint32) uint32    { return uint32(p.heap[s]) }
+func (p *model) setSymbol(s, v uint32)     { p.heap[s] = byte(v) }
+func (p *model) freq(s uint32) uint32      { return uint32(p.heap[s+1]) }
+func (p *model) setFreq(s, v uint32)       { p.heap[s+1] = byte(v) }
+func (p *model) successor(s uint32) uint32 { return p.u32(s + 2) }
+func (p *model) setSuccessor(s, v uint32)  { p.setU32(s+2, v) }
+
+func (p *model) copyState(dst, src uint32) {
+	copy(p.heap[dst:dst+stateSize], p.heap[src:src+stateSize])
+}
+
+func (p *model) loadState(s uint32) (t [stateSize]byte) {
+	copy(t[:], p.heap[s:])
+	return
+}
+
+func (p *model) storeState(s uint32, t [stateSize]byte) {
+	copy(p.heap[s:], t[:])
+}
+
+func (p *model) swapStates(s1, s2 uint32) {
+	t := p.loadState(s1)
+	p.copyState(s1, s2)
+	p.storeState(s2, t)
+}
+
+// free block nodes: Stamp, Next and NU
+func (p *model) nodeStamp(n uint32) uint32 { return p.u32(n) }
+func (p *model) nodeNext(n uint32) uint32  { return p.u32(n + 4) }
+func (p *model) nodeNU(n uint32) uint32    { return p.u32(n + 8) }
+func (p *model) setNodeStamp(n, v uint32)  { p.setU32(n, v) }
+func (p *model) setNodeNext(n, v uint32)   { p.setU32(n+4, v) }
+func (p *model) setNodeNU(n, v uint32)     { p.setU32(n+8, v) }
+func u2b(nu uint32) uint32                 { return nu * unitSize }
+func u2i(nu uint32) uint32                 { return uint32(units2Indx[nu-1]) }
+func i2u(indx uint32) uint32               { return uint32(indx2Units[indx]) }
+
+func (p *model) memcpy12(dst, src, nu uint32) {
+	copy(p.heap[dst:dst+u2b(nu)], p.heap[src:src+u2b(nu)])
+}
+
+func (p *model) insertNode(node, indx uint32) {
+	p.setNodeStamp(node, emptyNode)
+	p.setNodeNext(node, p.freeList[indx])
+	p.setNodeNU(node, i2u(indx))
+	p.freeList[indx] = node
+	p.stamps[indx]++
+}
+
+func (p *model) removeNode(indx uint32) uint32 {
+	node := p.freeList[indx]
+	p.freeList[indx] = p.nodeNext(node)
+	p.stamps[indx]--
+	return node
+}
+
+func (p *model) splitBlock(ptr, oldIndx, newIndx uint32) {
+	nu := i2u(oldIndx) - i2u(newIndx)
+	ptr += u2b(i2u(newIndx))
+	i := u2i(nu)
+	if i2u(i) != nu {
+		i--
+		k := i2u(i)
+		p.insertNode(ptr+u2b(k), nu-k-1)
+	}
+	p.insertNode(ptr, i)
+}
+
+func (p *model) glueFreeBlocks() {
+	p.glueCount = 1 << 13
+	p.stamps = [numIndexes]uint32{}
+
+	// the order-0 context closes the heap, a guard is only needed at LoUnit
+	if p.loUnit != p.hiUnit {
+		p.setNodeStamp(p.loUnit, 0)
+	}
+
+	// chain every free block, merging those that follow each other
+	var head uint32
+	prev := func(v uint32) { head = v }
+	for i := range uint32(numIndexes) {
+		next := p.freeList[i]
+		p.freeList[i] = 0
+		for next != 0 {
+			node := next
+			if p.nodeNU(node) != 0 {
+				prev(next)
+				prev = func(v uint32) { p.setNodeNext(node, v) }
+				for {
+					node2 := node + u2b(p.nodeNU(node))
+					if p.nodeStamp(node2) != emptyNode {
+						break
+					}
+					p.setNodeNU(node, p.nodeNU(node)+p.nodeNU(node2))
+					p.setNodeNU(node2, 0)
+				}
+			}
+			next = p.nodeNext(node)
+		}
+	}
+	prev(0)
+
+	for head != 0 {
+		node := head
+		head = p.nodeNext(node)
+		nu := p.nodeNU(node)
+		if nu == 0 {
+			continue
+		}
+		for ; nu > 128; nu, node = nu-128, node+u2b(128) {
+			p.insertNode(node, numIndexes-1)
+		}
+		i := u2i(nu)
+		if i2u(i) != nu {
+			i--
+			k := i2u(i)
+			p.insertNode(node+u2b(k), nu-k-1)
+		}
+		p.insertNode(node, i)
+	}
+}
+
+func (p *model) allocUnitsRare(indx uint32) uint32 {
+	if p.glueCount == 0 {
+		p.glueFreeBlocks()
+		if p.freeList[indx] != 0 {
+			return p.removeNode(indx)
+		}
+	}
+
+	i := indx
+	for {
+		if i++; i == numIndexes {
+			numBytes := u2b(i2u(indx))
+			p.glueCount--
+			if p.unitsStart-p.text > numBytes {
+				p.unitsStart -= numBytes
+				return p.unitsStart
+			}
+			return 0
+		}
+		if p.freeList[i] != 0 {
+			break
+		}
+	}
+	block := p.removeNode(i)
+	p.splitBlock(block, i, indx)
+	return block
+}
+
+func (p *model) allocUnits(indx uint32) uint32 {
+	if p.freeList[indx] != 0 {
+		return p.removeNode(indx)
+	}
+	numBytes := u2b(i2u(indx))
+	if numBytes <= p.hiUnit-p.loUnit {
+		block := p.loUnit
+		p.loUnit += numBytes
+		return block
+	}
+	return p.allocUnitsRare(indx)
+}
+
+func (p *model) shrinkUnits(oldPtr, oldNU, newNU uint32) uint32 {
+	i0 := u2i(oldNU)
+	i1 := u2i(newNU)
+	if i0 == i1 {
+		return oldPtr
+	}
+	if p.freeList[i1] != 0 {
+		ptr := p.removeNode(i1)
+		p.memcpy12(ptr, oldPtr, newNU)
+		p.insertNode(oldPtr, i0)
+		return ptr
+	}
+	p.splitBlock(oldPtr, i0, i1)
+	return oldPtr
+}
+
+func (p *model) freeUnits(ptr, nu uint32) {
+	p.insertNode(ptr, u2i(nu))
+}
+
+func (p *model) specialFreeUnit(ptr uint32) {
+	if ptr != p.unitsStart {
+		p.insertNode(ptr, 0)
+	} else {
+		p.setNodeStamp(ptr, emptyNode)
+		p.unitsStart += unitSize
+	}
+}
+
+func (p *model) moveUnitsUp(oldPtr, nu uint32) uint32 {
+	indx := u2i(nu)
+	if oldPtr > p.unitsStart+16*1024 || oldPtr > p.freeList[indx] {
+		return oldPtr
+	}
+	ptr := p.removeNode(indx)
+	p.memcpy12(ptr, oldPtr, nu)
+	if oldPtr != p.unitsStart {
+		p.insertNode(oldPtr, indx)
+	} else {
+		p.unitsStart += u2b(i2u(indx))
+	}
+	return ptr
+}
+
+func (p *model) expandTextArea() {
+	var count [numIndexes]uint32
+	if p.loUnit != p.hiUnit {
+		p.setNodeStamp(p.loUnit, 0)
+	}
+
+	node := p.unitsStart
+	for p.nodeStamp(node) == emptyNode {
+		p.setNodeStamp(node, 0)
+		count[u2i(p.nodeNU(node))]++
+		node += u2b(p.nodeNU(node))
+	}
+	p.unitsStart = node
+
+	for i := range uint32(numIndexes) {
+		// next is the location of a link, in the free list or in a node
+		get := func() uint32 { return p.freeList[i] }
+		set := func(v uint32) { p.freeList[i] = v }
+		for count[i] != 0 {
+			node := get()
+			for p.nodeStamp(node) == 0 {
+				set(p.nodeNext(node))
+				node = get()
+				p.stamps[i]--
+				if count[i]--; count[i] == 0 {
+					break
+				}
+			}
+			link := node
+			get = func() uint32 { return p.nodeNext(link) }
+			set = func(v uint32) { p.setNodeNext(link, v) }
+		}
+	}
+}
+
+func (p *model) restartModel() {
+	p.freeList = [numIndexes]uint32{}
+	p.stamps = [numIndexes]uint32{}
+	p.text = p.alignOffset
+	p.hiUnit = p.text + p.size
+	p.loUnit = p.hiUnit - p.size/8/unitSize*7*unitSize
+	p.unitsStart = p.loUnit
+	p.glueCount = 0
+
+	p.orderFall = p.maxOrder
+	p.initRL = -int32(min(p.maxOrder, maxOrderCap)) - 1
+	p.runLength = p.initRL
+	p.prevSuccess = 0
+
+	p.hiUnit -= unitSize
+	p.minContext = p.hiUnit
+	p.maxContext = p.hiUnit
+	p.setSuffix(p.minContext, 0)
+	p.setNumStats(p.minContext, 255)
+	p.setFlags(p.minContext, 0)
+	p.setSummFreq(p.minContext, 256+1)
+	p.foundState = p.loUnit
+	p.loUnit += u2b(256 / 2)
+	p.setStats(p.minContext, p.foundState)
+	for i := range uint32(256) {
+		s := p.foundState + i*stateSize
+		p.setSymbol(s, i)
+		p.setFreq(s, 1)
+		p.setSuccessor(s, 0)
+	}
+
+	for i, m := 0, 0; m < 25; m++ {
+		for int(ns2Indx[i]) == m {
+			i++
+		}
+		for k := range 8 {
+			val := uint16(binScale - uint32(initBinEsc[k])/uint32(i+1))
+			for r := 0; r < 64; r += 8 {
+				p.binSumm[m][k+r] = val
+			}
+		}
+	}
+
+	for i, m := 0, 0; m < 24; m++ {
+		for int(ns2Indx[i+3]) == m+3 {
+			i++
+		}
+		for k := range 32 {
+			s := &p.see[m][k]
+			s.shift = periodBits - 4
+			s.summ = uint16((2*i + 5) << s.shift)
+			s.count = 7
+		}
+	}
+}
+
+func (p *model) refresh(c, oldNU, scale uint32) {
+	i := p.numStats(c)
+	s := p.shrinkUnits(p.stats(c), oldNU, (i+2)>>1)
+	p.setStats(c, s)
+
+	if p.summFreq(c) >= 1<<15 {
+		scale |= 1
+	}
+	flags := p.flags(c)&(0x10+0x04*scale) + 0x08*b2u(p.symbol(s) >= 0x40)
+	escFreq := p.summFreq(c) - p.freq(s)
+	p.setFreq(s, (p.freq(s)+scale)>>scale)
+	sumFreq := p.freq(s)
+	for ; i > 0; i-- {
+		s += stateSize
+		escFreq -= p.freq(s)
+		p.setFreq(s, (p.freq(s)+scale)>>scale)
+		sumFreq += p.freq(s)
+		flags |= 0x08 * b2u(p.symbol(s) >= 0x40)
+	}
+	p.setSummFreq(c, sumFreq+(escFreq+scale)>>scale)
+	p.setFlags(c, flags)
+}
+
+func (p *model) cutOff(c, order uint32) uint32 {
+	if p.numStats(c) == 0 {
+		s := oneState(c)
+		if p.successor(s) >= p.unitsStart {
+			if order < p.maxOrder {
+				p.setSuccessor(s, p.cutOff(p.successor(s), order+1))
+			} else {
+				p.setSuccessor(s, 0)
+			}
+			if p.successor(s) != 0 || order <= 9 {
+				return c
+			}
+		}
+		p.specialFreeUnit(c)
+		return 0
+	}
+
+	tmp := (p.numStats(c) + 2) >> 1
+	p.setStats(c, p.moveUnitsUp(p.stats(c), tmp))
+
+	i := int(p.numStats(c))
+	for s := p.stats(c) + uint32(i)*stateSize; s >= p.stats(c); s -= stateSize {
+		if p.successor(s) < p.unitsStart {
+			s2 := p.stats(c) + uint32(i)*stateSize
+			i--
+			p.setSuccessor(s, 0)
+			p.swapStates(s, s2)
+		} else if order < p.maxOrder {
+			p.setSuccessor(s, p.cutOff(p.successor(s), order+1))
+		} else {
+			p.setSuccessor(s, 0)
+		}
+	}
+
+	if i != int(p.numStats(c)) && order != 0 {
+		p.setNumStats(c, uint32(i))
+		s := p.stats(c)
+		if i < 0 {
+			p.freeUnits(s, tmp)
+			p.specialFreeUnit(c)
+			return 0
+		}
+		if i == 0 {
+			p.setFlags(c, p.flags(c)&0x10+0x08*b2u(p.symbol(s) >= 0x40))
+			p.copyState(oneState(c), s)
+			p.freeUnits(s, tmp)
+			p.setFreq(oneState(c), (p.freq(oneState(c))+11)>>3)
+		} else {
+			p.refresh(c, tmp, b2u(p.summFreq(c) > 16*uint32(i)))
+		}
+	}
+	return c
+}
+
+func (p *model) getUsedMemory() uint32 {
+	v := uint32(0)
+	for i := range uint32(numIndexes) {
+		v += p.stamps[i] * i2u(i)
+	}
+	return p.size - (p.hiUnit - p.loUnit) - (p.unitsStart - p.text) - u2b(v)
+}
+
+func (p *model) restoreModel(c1 uint32) {
+	p.text = p.alignOffset
+
+	c := p.maxContext
+	for ; c != c1; c = p.suffix(c) {
+		p.setNumStats(c, p.numStats(c)-1)
+		if p.numStats(c) == 0 {
+			s := p.stats(c)
+			p.setFlags(c, p.flags(c)&0x10+0x08*b2u(p.symbol(s) >= 0x40))
+			p.copyState(oneState(c), s)
+			p.specialFreeUnit(s)
+			p.setFreq(oneState(c), (p.freq(oneState(c))+11)>>3)
+		} else {
+			p.refresh(c, (p.numStats(c)+3)>>1, 0)
+		}
+	}
+
+	for ; c != p.minContext; c = p.suffix(c) {
+		if p.numStats(c) == 0 {
+			s := oneState(c)
+			p.setFreq(s, p.freq(s)-p.freq(s)>>1)
+		} else {
+			p.setSummFreq(c, p.summFreq(c)+4)
+			if p.summFreq(c) > 128+4*p.numStats(c) {
+				p.refresh(c, (p.numStats(c)+2)>>1, 1)
+			}
+		}
+	}
+
+	if p.restoreMethod == RestoreRestart || p.getUsedMemory() < p.size>>1 {
+		p.restartModel()
+		return
+	}
+
+	for p.suffix(p.maxContext) != 0 {
+		p.maxContext = p.suffix(p.maxContext)
+	}
+	for {
+		p.cutOff(p.maxContext, 0)
+		p.expandTextArea()
+		if p.getUsedMemory() <= 3*(p.size>>2) {
+			break
+		}
+	}
+	p.glueCount = 0
+	p.orderFall = p.maxOrder
+}
+
+func (p *model) createSuccessors(skip bool, s1, c uint32) uint32 {
+	upBranch := p.successor(p.foundState)
+	var ps [MaxOrder + 1]uint32
+	numPs := 0
+
+	if !skip {
+		ps[numPs] = p.foundState
+		numPs++
+	}
+
+	for p.suffix(c) != 0 {
+		var s uint32
+		c = p.suffix(c)
+		if s1 != 0 {
+			s = s1
+			s1 = 0
+		} else if p.numStats(c) != 0 {
+			for s = p.stats(c); p.symbol(s) != p.symbol(p.foundState); s += stateSize {
+			}
+			if p.freq(s) < maxFreq-9 {
+				p.setFreq(s, p.freq(s)+1)
+				p.setSummFreq(c, p.summFreq(c)+1)
+			}
+		} else {
+			s = oneState(c)
+			p.setFreq(s, p.freq(s)+b2u(p.numStats(p.suffix(c)) == 0 && p.freq(s) < 24))
+		}
+		successor := p.successor(s)
+		if successor != upBranch {
+			c = successor
+			if numPs == 0 {
+				return c
+			}
+			break
+		}
+		ps[numPs] = s
+		numPs++
+	}
+
+	upSymbol := uint32(p.heap[upBranch])
+	upSuccessor := upBranch + 1
+	flags := 0x10*b2u(p.symbol(p.foundState) >= 0x40) + 0x08*b2u(upSymbol >= 0x40)
+
+	var upFreq uint32
+	if p.numStats(c) == 0 {
+		upFreq = p.freq(oneState(c))
+	} else {
+		var s uint32
+		for s = p.stats(c); p.symbol(s) != upSymbol; s += stateSize {
+		}
+		cf := p.freq(s) - 1
+		s0 := p.summFreq(c) - p.numStats(c) - cf
+		if 2*cf <= s0 {
+			upFreq = 1 + b2u(5*cf > s0)
+		} else {
+			upFreq = 1 + (cf+2*s0-3)/s0
+		}
+	}
+
+	for numPs != 0 {
+		var c1 uint32
+		if p.hiUnit != p.loUnit {
+			p.hiUnit -= unitSize
+			c1 = p.hiUnit
+		} else if p.freeList[0] != 0 {
+			c1 = p.removeNode(0)
+		} else {
+			c1 = p.allocUnitsRare(0)
+			if c1 == 0 {
+				return 0
+			}
+		}
+		p.setNumStats(c1, 0)
+		p.setFlags(c1, flags)
+		s := oneState(c1)
+		p.setSymbol(s, upSymbol)
+		p.setFreq(s, upFreq)
+		p.setSuccessor(s, upSuccessor)
+		p.setSuffix(c1, c)
+		numPs--
+		p.setSuccessor(ps[numPs], c1)
+		c = c1
+	}
+	return c
+}
+
+func (p *model) reduceOrder(s1, c uint32) uint32 {
+	var s uint32
+	c1 := c
+	upBranch := p.text
+
+	p.setSuccessor(p.foundState, upBranch)
+	p.orderFall++
+
+	for {
+		if s1 != 0 {
+			c = p.suffix(c)
+			s = s1
+			s1 = 0
+		} else {
+			if p.suffix(c) == 0 {
+				return c
+			}
+			c = p.suffix(c)
+			if p.numStats(c) != 0 {
+				for s = p.stats(c); p.symbol(s) != p.symbol(p.foundState); s += stateSize {
+				}
+				if p.freq(s) < maxFreq-9 {
+					p.setFreq(s, p.freq(s)+2)
+					p.setSummFreq(c, p.summFreq(c)+2)
+				}
+			} else {
+				s = oneState(c)
+				p.setFreq(s, p.freq(s)+b2u(p.freq(s) < 32))
+			}
+		}
+		if p.successor(s) != 0 {
+			break
+		}
+		p.setSuccessor(s, upBranch)
+		p.orderFall++
+	}
+
+	if p.successor(s) <= upBranch {
+		s2 := p.foundState
+		p.foundState = s
+		successor := p.createSuccessors(false, 0, c)
+		p.setSuccessor(s, successor)
+		p.foundState = s2
+	}
+
+	if p.orderFall == 1 && c1 == p.maxContext {
+		p.setSuccessor(p.foundState, p.successor(s))
+		p.text--
+	}
+	return p.successor(s)
+}
+
+func (p *model) updateModel() {
+	fSuccessor := p.successor(p.foundState)
+	fFreq := p.freq(p.foundState)
+	fSymbol := p.symbol(p.foundState)
+	var s uint32
+
+	if fFreq < maxFreq/4 && p.suffix(p.minContext) != 0 {
+		c := p.suffix(p.minContext)
+		if p.numStats(c) == 0 {
+			s = oneState(c)
+			if p.freq(s) < 32 {
+				p.setFreq(s, p.freq(s)+1)
+			}
+		} else {
+			s = p.stats(c)
+			if p.symbol(s) != fSymbol {
+				for s += stateSize; p.symbol(s) != fSymbol; s += stateSize {
+				}
+				if p.freq(s) >= p.freq(s-stateSize) {
+					p.swapStates(s, s-stateSize)
+					s -= stateSize
+				}
+			}
+			if p.freq(s) < maxFreq-9 {
+				p.setFreq(s, p.freq(s)+2)
+				p.setSummFreq(c, p.summFreq(c)+2)
+			}
+		}
+	}
+
+	c := p.maxContext
+	if p.orderFall == 0 && fSuccessor != 0 {
+		cs := p.createSuccessors(true, s, p.minContext)
+		if cs == 0 {
+			p.setSuccessor(p.foundState, 0)
+			p.restoreModel(c)
+		} else {
+			p.setSuccessor(p.foundState, cs)
+			p.maxContext = cs
+		}
+		return
+	}
+
+	p.heap[p.text] = byte(fSymbol)
+	p.text++
+	successor := p.text
+	if p.text >= p.unitsStart {
+		p.restoreModel(c)
+		return
+	}
+
+	if fSuccessor == 0 {
+		cs := p.reduceOrder(s, p.minContext)
+		if cs == 0 {
+			p.restoreModel(c)
+			return
+		}
+		fSuccessor = cs
+	} else if fSuccessor < p.unitsStart {
+		cs := p.createSuccessors(false, s, p.minContext)
+		if cs == 0 {
+			p.restoreModel(c)
+			return
+		}
+		fSuccessor = cs
+	}
+
+	if p.orderFall--; p.orderFall == 0 {
+		successor = fSuccessor
+		if p.maxContext != p.minContext {
+			p.text--
+		}
+	}
+
+	ns := p.numStats(p.minContext)
+	s0 := p.summFreq(p.minContext) - ns - fFreq
+	flag := 0x08 * b2u(fSymbol >= 0x40)
+
+	for ; c != p.minContext; c = p.suffix(c) {
+		ns1 := p.numStats(c)
+		if ns1 != 0 {
+			if ns1&1 != 0 {
+				// the stats grow by one unit every second symbol
+				oldNU := (ns1 + 1) >> 1
+				i := u2i(oldNU)
+				if i != u2i(oldNU+1) {
+					ptr := p.allocUnits(i + 1)
+					if ptr == 0 {
+						p.restoreModel(c)
+						return
+					}
+					oldPtr := p.stats(c)
+					p.memcpy12(ptr, oldPtr, oldNU)
+					p.insertNode(oldPtr, i)
+					p.setStats(c, ptr)
+				}
+			}
+			p.setSummFreq(c, p.summFreq(c)+b2u(3*ns1+1 < ns))
+		} else {
+			s2 := p.allocUnits(0)
+			if s2 == 0 {
+				p.restoreModel(c)
+				return
+			}
+			p.copyState(s2, oneState(c))
+			p.setStats(c, s2)
+			if p.freq(s2) < maxFreq/4-1 {
+				p.setFreq(s2, p.freq(s2)<<1)
+			} else {
+				p.setFreq(s2, maxFreq-4)
+			}
+			p.setSummFreq(c, p.freq(s2)+p.initEsc+b2u(ns > 2))
+		}
+
+		cf := 2 * fFreq * (p.summFreq(c) + 6)
+		sf := s0 + p.summFreq(c)
+		if cf < 6*sf {
+			cf = 1 + b2u(cf > sf) + b2u(cf >= 4*sf)
+			p.setSummFreq(c, p.summFreq(c)+4)
+		} else {
+			cf = 4 + b2u(cf > 9*sf) + b2u(cf > 12*sf) + b2u(cf > 15*sf)
+			p.setSummFreq(c, p.summFreq(c)+cf)
+		}
+
+		s2 := p.stats(c) + (ns1+1)*stateSize
+		p.setSuccessor(s2, successor)
+		p.setSymbol(s2, fSymbol)
+		p.setFreq(s2, cf)
+		p.setFlags(c, p.flags(c)|flag)
+		p.setNumStats(c, ns1+1)
+	}
+
+	p.maxContext = fSuccessor
+	p.minContext = fSuccessor
+}
+
+func (p *model) rescale() {
+	stats := p.stats(p.minContext)
+	s := p.foundState
+
+	// the found state moves to the front
+	if s != stats {
+		t := p.loadState(s)
+		for ; s != stats; s -= stateSize {
+			p.copyState(s, s-stateSize)
+		}
+		p.storeState(s, t)
+	}
+
+	escFreq := p.summFreq(p.minContext) - p.freq(s)
+	p.setFreq(s, p.freq(s)+4)
+	adder := b2u(p.orderFall != 0)
+	p.setFreq(s, (p.freq(s)+adder)>>1)
+	sumFreq := p.freq(s)
+
+	i := p.numStats(p.minContext)
+	for ; i > 0; i-- {
+		s += stateSize
+		escFreq -= p.freq(s)
+		p.setFreq(s, (p.freq(s)+adder)>>1)
+		sumFreq += p.freq(s)
+		if p.freq(s) > p.freq(s-stateSize) {
+			// keep the states sorted by frequency
+			t := p.loadState(s)
+			s1 := s
+			for {
+				p.copyState(s1, s1-stateSize)
+				s1 -= stateSize
+				if s1 == stats || uint32(t[1]) <= p.freq(s1-stateSize) {
+					break
+				}
+			}
+			p.storeState(s1, t)
+		}
+	}
+
+	if p.freq(s) == 0 {
+		numStats := p.numStats(p.minContext)
+		i = 0
+		for {
+			i++
+			s -= stateSize
+			if p.freq(s) != 0 {
+				break
+			}
+		}
+		escFreq += i
+		p.setNumStats(p.minContext, p.numStats(p.minContext)-i)
+		if p.numStats(p.minContext) == 0 {
+			t := p.loadState(stats)
+			freq := (2*uint32(t[1]) + escFreq - 1) / escFreq
+			t[1] = byte(min(freq, maxFreq/3))
+			p.insertNode(stats, u2i((numStats+2)>>1))
+			p.setFlags(p.minContext, p.flags(p.minContext)&0x10+0x08*b2u(uint32(t[0]) >= 0x40))
+			p.foundState = oneState(p.minContext)
+			p.storeState(p.foundState, t)
+			return
+		}
+
+		n0 := (numStats + 2) >> 1
+		n1 := (p.numStats(p.minContext) + 2) >> 1
+		if n0 != n1 {
+			p.setStats(p.minContext, p.shrinkUnits(stats, n0, n1))
+		}
+		flags := p.flags(p.minContext) &^ 0x08
+		s = p.stats(p.minContext)
+		flags |= 0x08 * b2u(p.symbol(s) >= 0x40)
+		for i = p.numStats(p.minContext); i > 0; i-- {
+			s += stateSize
+			flags |= 0x08 * b2u(p.symbol(s) >= 0x40)
+		}
+		p.setFlags(p.minContext, flags)
+	}
+
+	p.setSummFreq(p.minContext, sumFreq+escFreq-escFreq>>1)
+	p.setFlags(p.minContext, p.flags(p.minContext)|0x04)
+	p.foundState = p.stats(p.minContext)
+}
+
+func (p *model) makeEscFreq(numMasked uint32) (*see, uint32) {
+	mc := p.minContext
+	numStats := p.numStats(mc)
+	if numStats == 0xff {
+		return &p.dummySee, 1
+	}
+
+	k := b2u(p.summFreq(mc) > 11*(numStats+1)) +
+		2*b2u(2*numStats < p.numStats(p.suffix(mc))+numMasked) +
+		p.flags(mc)
+	s := &p.see[ns2Indx[numStats+2]-3][k]
+	r := uint32(s.summ >> s.shift)
+	s.summ -= uint16(r)
+	return s, r + b2u(r == 0)
+}
+
+func (p *model) nextContext() {
+	c := p.successor(p.foundState)
+	if p.orderFall == 0 && c >= p.unitsStart {
+		p.minContext = c
+		p.maxContext = c
+	} else {
+		p.updateModel()
+		p.minContext = p.maxContext
+	}
+}
+
+func (p *model) update1() {
+	s := p.foundState
+	p.setFreq(s, p.freq(s)+4)
+	p.setSummFreq(p.minContext, p.summFreq(p.minContext)+4)
+	if p.freq(s) > p.freq(s-stateSize) {
+		p.swapStates(s, s-stateSize)
+		s -= stateSize
+		p.foundState = s
+		if p.freq(s) > maxFreq {
+			p.rescale()
+		}
+	}
+	p.nextContext()
+}
+
+func (p *model) update1First() {
+	p.prevSuccess = b2u(2*p.freq(p.foundState) >= p.summFreq(p.minContext))
+	p.runLength += int32(p.prevSuccess)
+	p.setSummFreq(p.minContext, p.summFreq(p.minContext)+4)
+	p.setFreq(p.foundState, p.freq(p.foundState)+4)
+	if p.freq(p.foundState) > maxFreq {
+		p.rescale()
+	}
+	p.nextContext()
+}
+
+func (p *model) updateBin() {
+	s := p.foundState
+	p.setFreq(s, p.freq(s)+b2u(p.freq(s) < 196))
+	p.prevSuccess = 1
+	p.runLength++
+	p.nextContext()
+}
+
+func (p *model) update2() {
+	p.setSummFreq(p.minContext, p.summFreq(p.minContext)+4)
+	p.setFreq(p.foundState, p.freq(p.foundState)+4)
+	if p.freq(p.foundState) > maxFreq {
+		p.rescale()
+	}
+	p.runLength = p.initRL
+	p.updateModel()
+	p.minContext = p.maxContext
+}
+
+func (p *model) binSummFor() *uint16 {
+	mc := p.minContext
+	i := ns2BSIndx[p.numStats(p.suffix(mc))]
+	k := uint32(i) + p.prevSuccess + p.flags(mc) + uint32(p.runLength>>26)&0x20
+	return &p.binSumm[ns2Indx[p.freq(oneState(mc))-1]][k]
+}
+
+func b2u(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
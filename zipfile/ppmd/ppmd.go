@@ -0,0 +1,517 @@
+package ppmd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+var (
+	ErrCorrupt    = errors.New("zip: corrupt PPMd data")
+	ErrParameters = errors.New("zip: unsupported PPMd parameters")
+)
+
+const (
+	MinMemory = 1
+	MaxMemory = 256
+
+	rangeTop = 1 << 24
+	rangeBot = 1 << 15
+)
+
+// Parameters are stored in the two bytes before the data: the model order
+// minus one in bits 0-3, the memory size in MiB minus one in bits 4-11 and
+// the restoration method in bits 12-15.
+type Parameters struct {
+	Order         int
+	MemoryMB      int
+	RestoreMethod int
+}
+
+// LevelParameters returns the parameters of a compression level, 1 to 9, as
+// chosen by 7-Zip. Other levels take the default of 5.
+func LevelParameters(level int) Parameters {
+	if level < 1 || level > 9 {
+		level = 5
+	}
+	params := Parameters{
+		Order:         3 + level,
+		MemoryMB:      1 << (min(level, 8) - 1),
+		RestoreMethod: RestoreRestart,
+	}
+	if level >= 7 {
+		params.RestoreMethod = RestoreCutOff
+	}
+	return params
+}
+
+func (params Parameters) valid() bool {
+	return params.Order >= MinOrder && params.Order <= MaxOrder &&
+		params.MemoryMB >= MinMemory && params.MemoryMB <= MaxMemory &&
+		(params.RestoreMethod == RestoreRestart || params.RestoreMethod == RestoreCutOff)
+}
+
+func (params Parameters) header() [2]byte {
+	v := uint16(params.Order-1) | uint16(params.MemoryMB-1)<<4 | uint16(params.RestoreMethod)<<12
+	return [2]byte{byte(v), byte(v >> 8)}
+}
+
+func parseHeader(b [2]byte) Parameters {
+	v := uint16(b[0]) | uint16(b[1])<<8
+	return Parameters{
+		Order:         int(v&0xf) + 1,
+		MemoryMB:      int(v>>4&0xff) + 1,
+		RestoreMethod: int(v >> 12),
+	}
+}
+
+func (params Parameters) model() *model {
+	return newModel(uint32(params.Order), uint32(params.MemoryMB)<<20, uint32(params.RestoreMethod))
+}
+
+// rangeDecoder is the carryless range coder of Dmitry Subbotin.
+type rangeDecoder struct {
+	r    io.ByteReader
+	low  uint32
+	rng  uint32
+	code uint32
+	err  error
+}
+
+func (rc *rangeDecoder) readByte() uint32 {
+	b, err := rc.r.ReadByte()
+	if err != nil {
+		// reads past the end give zeros, the data may stop short of them
+		if err != io.EOF && rc.err == nil {
+			rc.err = err
+		}
+		return 0
+	}
+	return uint32(b)
+}
+
+func (rc *rangeDecoder) init() bool {
+	rc.low = 0
+	rc.rng = 0xffffffff
+	rc.code = 0
+	for range 4 {
+		rc.code = rc.code<<8 | rc.readByte()
+	}
+	return rc.code < 0xffffffff
+}
+
+func (rc *rangeDecoder) threshold(total uint32) uint32 {
+	rc.rng /= total
+	return rc.code / rc.rng
+}
+
+func (rc *rangeDecoder) decode(start, size uint32) {
+	start *= rc.rng
+	rc.low += start
+	rc.code -= start
+	rc.rng *= size
+	for {
+		if rc.low^(rc.low+rc.rng) >= rangeTop {
+			if rc.rng >= rangeBot {
+				break
+			}
+			rc.rng = -rc.low & (rangeBot - 1)
+		}
+		rc.code = rc.code<<8 | rc.readByte()
+		rc.rng <<= 8
+		rc.low <<= 8
+	}
+}
+
+type rangeEncoder struct {
+	w   *bufio.Writer
+	low uint32
+	rng uint32
+}
+
+func (rc *rangeEncoder) normalize() {
+	for {
+		if rc.low^(rc.low+rc.rng) >= rangeTop {
+			if rc.rng >= rangeBot {
+				break
+			}
+			rc.rng = -rc.low & (rangeBot - 1)
+		}
+		rc.w.WriteByte(byte(rc.low >> 24))
+		rc.rng <<= 8
+		rc.low <<= 8
+	}
+}
+
+func (rc *rangeEncoder) encode(start, size, total uint32) {
+	rc.rng /= total
+	rc.low += start * rc.rng
+	rc.rng *= size
+	rc.normalize()
+}
+
+func (rc *rangeEncoder) encodeBit0(size0 uint32) {
+	rc.rng >>= 14
+	rc.rng *= size0
+	rc.normalize()
+}
+
+func (rc *rangeEncoder) encodeBit1(size0 uint32) {
+	rc.rng >>= 14
+	rc.low += size0 * rc.rng
+	rc.rng *= binScale - size0
+	rc.normalize()
+}
+
+func (rc *rangeEncoder) flush() {
+	for range 4 {
+		rc.w.WriteByte(byte(rc.low >> 24))
+		rc.low <<= 8
+	}
+}
+
+// decodeSymbol returns the next byte, -1 at the end marker or -2 on corrupt
+// data.
+func (p *model) decodeSymbol(rc *rangeDecoder) int {
+	var charMask [256]byte
+	mc := p.minContext
+	if p.numStats(mc) != 0 {
+		s := p.stats(mc)
+		count := rc.threshold(p.summFreq(mc))
+		hiCnt := p.freq(s)
+		if count < hiCnt {
+			rc.decode(0, p.freq(s))
+			p.foundState = s
+			symbol := p.symbol(s)
+			p.update1First()
+			return int(symbol)
+		}
+		p.prevSuccess = 0
+		for i := p.numStats(mc); i > 0; i-- {
+			s += stateSize
+			if hiCnt += p.freq(s); hiCnt > count {
+				rc.decode(hiCnt-p.freq(s), p.freq(s))
+				p.foundState = s
+				symbol := p.symbol(s)
+				p.update1()
+				return int(symbol)
+			}
+		}
+		if count >= p.summFreq(mc) {
+			return -2
+		}
+		rc.decode(hiCnt, p.summFreq(mc)-hiCnt)
+		for i := range charMask {
+			charMask[i] = 0xff
+		}
+		charMask[p.symbol(s)] = 0
+		for i := p.numStats(mc); i > 0; i-- {
+			s -= stateSize
+			charMask[p.symbol(s)] = 0
+		}
+	} else {
+		prob := p.binSummFor()
+		rc.rng >>= 14
+		if rc.code/rc.rng < uint32(*prob) {
+			rc.decode(0, uint32(*prob))
+			*prob = *prob + 1<<intBits - getMean(*prob)
+			p.foundState = oneState(mc)
+			symbol := p.symbol(p.foundState)
+			p.updateBin()
+			return int(symbol)
+		}
+		rc.decode(uint32(*prob), binScale-uint32(*prob))
+		*prob -= getMean(*prob)
+		p.initEsc = uint32(expEscape[*prob>>10])
+		for i := range charMask {
+			charMask[i] = 0xff
+		}
+		charMask[p.symbol(oneState(mc))] = 0
+		p.prevSuccess = 0
+	}
+
+	var ps [256]uint32
+	for {
+		numMasked := p.numStats(p.minContext)
+		for {
+			p.orderFall++
+			if p.suffix(p.minContext) == 0 {
+				return -1
+			}
+			p.minContext = p.suffix(p.minContext)
+			if p.numStats(p.minContext) != numMasked {
+				break
+			}
+		}
+
+		hiCnt := uint32(0)
+		s := p.stats(p.minContext)
+		num := p.numStats(p.minContext) - numMasked
+		i := uint32(0)
+		for i != num {
+			if charMask[p.symbol(s)] != 0 {
+				hiCnt += p.freq(s)
+				ps[i] = s
+				i++
+			}
+			s += stateSize
+		}
+
+		see, freqSum := p.makeEscFreq(numMasked)
+		freqSum += hiCnt
+		count := rc.threshold(freqSum)
+
+		if count < hiCnt {
+			k := 0
+			hiCnt = p.freq(ps[0])
+			for hiCnt <= count {
+				k++
+				hiCnt += p.freq(ps[k])
+			}
+			s = ps[k]
+			rc.decode(hiCnt-p.freq(s), p.freq(s))
+			see.update()
+			p.foundState = s
+			symbol := p.symbol(s)
+			p.update2()
+			return int(symbol)
+		}
+		if count >= freqSum {
+			return -2
+		}
+		rc.decode(hiCnt, freqSum-hiCnt)
+		see.summ += uint16(freqSum)
+		for ; i > 0; i-- {
+			charMask[p.symbol(ps[i-1])] = 0
+		}
+	}
+}
+
+// encodeSymbol codes a byte, or the end marker for -1.
+func (p *model) encodeSymbol(rc *rangeEncoder, symbol int) {
+	var charMask [256]byte
+	mc := p.minContext
+	if p.numStats(mc) != 0 {
+		s := p.stats(mc)
+		if int(p.symbol(s)) == symbol {
+			rc.encode(0, p.freq(s), p.summFreq(mc))
+			p.foundState = s
+			p.update1First()
+			return
+		}
+		p.prevSuccess = 0
+		sum := p.freq(s)
+		for i := p.numStats(mc); i > 0; i-- {
+			s += stateSize
+			if int(p.symbol(s)) == symbol {
+				rc.encode(sum, p.freq(s), p.summFreq(mc))
+				p.foundState = s
+				p.update1()
+				return
+			}
+			sum += p.freq(s)
+		}
+
+		for i := range charMask {
+			charMask[i] = 0xff
+		}
+		charMask[p.symbol(s)] = 0
+		for i := p.numStats(mc); i > 0; i-- {
+			s -= stateSize
+			charMask[p.symbol(s)] = 0
+		}
+		rc.encode(sum, p.summFreq(mc)-sum, p.summFreq(mc))
+	} else {
+		prob := p.binSummFor()
+		s := oneState(mc)
+		if int(p.symbol(s)) == symbol {
+			rc.encodeBit0(uint32(*prob))
+			*prob = *prob + 1<<intBits - getMean(*prob)
+			p.foundState = s
+			p.updateBin()
+			return
+		}
+		rc.encodeBit1(uint32(*prob))
+		*prob -= getMean(*prob)
+		p.initEsc = uint32(expEscape[*prob>>10])
+		for i := range charMask {
+			charMask[i] = 0xff
+		}
+		charMask[p.symbol(s)] = 0
+		p.prevSuccess = 0
+	}
+
+	for {
+		numMasked := p.numStats(p.minContext)
+		for {
+			p.orderFall++
+			if p.suffix(p.minContext) == 0 {
+				// the end marker escapes from the order-0 context
+				return
+			}
+			p.minContext = p.suffix(p.minContext)
+			if p.numStats(p.minContext) != numMasked {
+				break
+			}
+		}
+
+		see, escFreq := p.makeEscFreq(numMasked)
+		s := p.stats(p.minContext)
+		sum := uint32(0)
+		for i := p.numStats(p.minContext) + 1; i > 0; i-- {
+			cur := p.symbol(s)
+			if int(cur) == symbol {
+				low := sum
+				s1 := s
+				for ; i > 0; i-- {
+					if charMask[p.symbol(s)] != 0 {
+						sum += p.freq(s)
+					}
+					s += stateSize
+				}
+				rc.encode(low, p.freq(s1), sum+escFreq)
+				see.update()
+				p.foundState = s1
+				p.update2()
+				return
+			}
+			if charMask[cur] != 0 {
+				sum += p.freq(s)
+			}
+			charMask[cur] = 0
+			s += stateSize
+		}
+
+		rc.encode(sum, escFreq, sum+escFreq)
+		see.summ += uint16(sum + escFreq)
+	}
+}
+
+func getMean(prob uint16) uint16 {
+	return (prob + 1<<(periodBits-2)) >> periodBits
+}
+
+// reader decodes up to size bytes, stopping early at the end marker.
+type reader struct {
+	r         io.Reader
+	remaining int64
+	model     *model
+	rc        rangeDecoder
+	err       error
+}
+
+// NewReader returns a reader decompressing size bytes of PPMd data that
+// starts with its parameters.
+func NewReader(r io.Reader, size int64) io.ReadCloser {
+	return &reader{r: r, remaining: size}
+}
+
+func (z *reader) init() error {
+	var b [2]byte
+	if _, err := io.ReadFull(z.r, b[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	params := parseHeader(b)
+	if !params.valid() {
+		return ErrParameters
+	}
+
+	br, ok := z.r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(z.r)
+	}
+	z.rc.r = br
+	if !z.rc.init() {
+		return ErrCorrupt
+	}
+	z.model = params.model()
+	return nil
+}
+
+func (z *reader) Read(p []byte) (n int, err error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.model == nil {
+		if z.err = z.init(); z.err != nil {
+			return 0, z.err
+		}
+	}
+
+	for n < len(p) && z.remaining > 0 {
+		symbol := z.model.decodeSymbol(&z.rc)
+		if z.rc.err != nil {
+			z.err = z.rc.err
+			return n, z.err
+		}
+		if symbol < 0 {
+			z.err = ErrCorrupt
+			if symbol == -1 {
+				z.err = io.ErrUnexpectedEOF
+			}
+			return n, z.err
+		}
+		p[n] = byte(symbol)
+		n++
+		z.remaining--
+	}
+	if z.remaining == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (z *reader) Close() error {
+	z.model = nil
+	z.err = errors.New("zip: read from closed PPMd reader")
+	return nil
+}
+
+type writer struct {
+	model  *model
+	rc     rangeEncoder
+	closed bool
+}
+
+// NewWriter returns a writer compressing to w with the given parameters,
+// which it writes first. Close writes the end marker.
+func NewWriter(w io.Writer, params Parameters) (io.WriteCloser, error) {
+	if !params.valid() {
+		return nil, ErrParameters
+	}
+	z := &writer{
+		model: params.model(),
+		rc:    rangeEncoder{w: bufio.NewWriter(w), rng: 0xffffffff},
+	}
+	header := params.header()
+	z.rc.w.Write(header[:])
+	return z, nil
+}
+
+func (z *writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, errors.New("zip: write to closed PPMd writer")
+	}
+	for _, b := range p {
+		z.model.encodeSymbol(&z.rc, int(b))
+	}
+	// the buffered writer keeps the first error
+	if _, err := z.rc.w.Write(nil); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (z *writer) Close() error {
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	z.model.encodeSymbol(&z.rc, -1)
+	z.rc.flush()
+	z.model = nil
+	return z.rc.w.Flush()
+}
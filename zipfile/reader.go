@@ -0,0 +1,497 @@
+package zipfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-zipfile/serial"
+	"go-zipfile/zipfile/dos"
+	"go-zipfile/zipfile/extrafield"
+	"go-zipfile/zipfile/posix"
+	"go-zipfile/zipfile/winzipaes"
+	"go-zipfile/zipfile/zipcrypto"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+)
+
+var (
+	ErrFormat    = errors.New("zip: not a valid zip file")
+	ErrAlgorithm = errors.New("zip: unsupported compression algorithm")
+	// ErrPassword reports a password that does not match the encryption header.
+	ErrPassword         = zipcrypto.ErrPassword
+	ErrPasswordRequired = errors.New("zip: password required for encrypted entry")
+	ErrAuthentication   = winzipaes.ErrAuthentication
+)
+
+type ChecksumError struct {
+	Name     string
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("zip: checksum error in %s: expected %08x, got %08x", e.Name, e.Expected, e.Actual)
+}
+
+type FileHeader struct {
+	Name                   string
+	Comment                string
+	Version                uint16
+	VersionNeeded          uint16
+	Flags                  uint16
+	CompressionMethod      uint16
+	Modified               time.Time
+	CRC32                  uint32
+	CompressedSize         uint64
+	UncompressedSize       uint64
+	ExtraField             []byte
+	InternalFileAttributes uint16
+	ExternalFileAttributes uint32
+}
+
+// VersionMadeBy returns the host system of the "version made by" field,
+// see MapOfVersionMadeBy.
+func (h *FileHeader) VersionMadeBy() uint8 {
+	return uint8(h.Version >> 8)
+}
+
+// ExtraFields parses the extra field block of the central header.
+func (h *FileHeader) ExtraFields() (extrafield.Fields, error) {
+	return extrafield.Parse(h.ExtraField)
+}
+
+func (h *FileHeader) IsDir() bool {
+	return strings.HasSuffix(h.Name, "/")
+}
+
+// Mode returns the file mode from the POSIX mode in the upper half of the
+// external attributes, or from the DOS attributes when there is none.
+func (h *FileHeader) Mode() (mode os.FileMode) {
+	if h.hasUnixMode() {
+		return unixModeToFileMode(uint16(h.ExternalFileAttributes >> 16))
+	}
+
+	mode = 0o666
+	if h.ExternalFileAttributes&dos.FileAttributeReadonly != 0 {
+		mode = 0o444
+	}
+	if h.ExternalFileAttributes&dos.FileAttributeDirectory != 0 || h.IsDir() {
+		mode |= os.ModeDir | 0o111
+	}
+	return
+}
+
+func (h *FileHeader) hasUnixMode() bool {
+	switch h.VersionMadeBy() {
+	case VersionMadeByUNIX, VersionMadeByOSX_Darwin:
+		return h.ExternalFileAttributes>>16 != 0
+	}
+	return false
+}
+
+func unixModeToFileMode(unixMode uint16) os.FileMode {
+	mode := os.FileMode(unixMode & 0o777)
+
+	switch unixMode & posix.StatFileTypeMask {
+	case posix.StatIsDirectory:
+		mode |= os.ModeDir
+	case posix.StatIsSymbolicLink:
+		mode |= os.ModeSymlink
+	case posix.StatIsNamedPipe:
+		mode |= os.ModeNamedPipe
+	case posix.StatIsCharacterDevice:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case posix.StatIsBlockDevice:
+		mode |= os.ModeDevice
+	case posix.StatIsSocket:
+		mode |= os.ModeSocket
+	}
+
+	if unixMode&posix.StatIsSetUserID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if unixMode&posix.StatIsSetGroupID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if unixMode&posix.StatIsSticky != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+type Entry struct {
+	FileHeader
+	reader              *Reader
+	offsetOfLocalHeader int64
+	dosTime             uint16
+	rawName             []byte
+	rawComment          []byte
+}
+
+type Reader struct {
+	r          io.ReaderAt
+	size       int64
+	baseOffset int64
+	Entries    []*Entry
+	Comment    string
+	rawComment []byte
+	password   []byte
+	codePage   encoding.Encoding
+}
+
+type ReadCloser struct {
+	Reader
+	file *os.File
+}
+
+func Open(name string) (*ReadCloser, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	rc := &ReadCloser{file: file}
+	if err = rc.init(file, stat.Size()); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return rc, nil
+}
+
+// SetPassword sets the password used to decrypt the entries opened from now on.
+func (zr *Reader) SetPassword(password string) {
+	zr.password = []byte(password)
+}
+
+func (rc *ReadCloser) Close() error {
+	return rc.file.Close()
+}
+
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	zr := &Reader{}
+	if err := zr.init(r, size); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+func (zr *Reader) init(r io.ReaderAt, size int64) (err error) {
+	zr.r = r
+	zr.size = size
+
+	eocdr, eocdrOffset, err := findEndOfCentralDirectory(r, size)
+	if err != nil {
+		return
+	}
+	zr.rawComment = eocdr.ZIPFileComment
+	zr.Comment = zr.decode(zr.rawComment, 0)
+
+	directoryEnd := eocdrOffset
+	directoryOffset := uint64(eocdr.OffsetOfStartingDiskNumber)
+	directorySize := uint64(eocdr.CentralDirectorySize)
+	totalEntries := uint64(eocdr.TotalEntries)
+
+	z64eocdr, z64eocdrOffset, err := findZip64EndOfCentralDirectory(r, eocdrOffset)
+	if err != nil {
+		return
+	}
+	if z64eocdr != nil {
+		directoryEnd = z64eocdrOffset
+		directoryOffset = z64eocdr.OffsetOfStartingDiskNumber
+		directorySize = z64eocdr.CentralDirectorySize
+		totalEntries = z64eocdr.TotalEntries
+	}
+
+	if directorySize > uint64(directoryEnd) || directoryOffset > uint64(directoryEnd)-directorySize {
+		return ErrFormat
+	}
+	// every central header takes at least 46 bytes
+	if totalEntries > directorySize/46 {
+		return ErrFormat
+	}
+
+	// data prepended to the archive (e.g. self-extracting stubs) shifts every offset
+	zr.baseOffset = directoryEnd - int64(directorySize) - int64(directoryOffset)
+
+	directory := make([]byte, directorySize)
+	if _, err = r.ReadAt(directory, zr.baseOffset+int64(directoryOffset)); err != nil {
+		return
+	}
+
+	reader := bytes.NewReader(directory)
+	zr.Entries = make([]*Entry, 0, totalEntries)
+	for range totalEntries {
+		var cdh CentralDirectoryFileHeader
+		if err = serial.Unmarshal(reader, &cdh); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return ErrFormat
+			}
+			return
+		}
+		if cdh.Signature != CentralFileHeaderSignature {
+			return ErrFormat
+		}
+
+		var entry *Entry
+		if entry, err = newEntry(zr, &cdh); err != nil {
+			return
+		}
+		zr.Entries = append(zr.Entries, entry)
+	}
+
+	return
+}
+
+func findEndOfCentralDirectory(r io.ReaderAt, size int64) (eocdr EndOfCentralDirectoryRecord, offset int64, err error) {
+	const minimumSize = 22
+	const maximumSize = minimumSize + 0xffff
+
+	if size < minimumSize {
+		return eocdr, 0, ErrFormat
+	}
+
+	length := min(size, maximumSize)
+	buf := make([]byte, length)
+	if _, err = r.ReadAt(buf, size-length); err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+
+	// the record ends with a variable size comment, so scan backwards for its signature
+	for i := len(buf) - minimumSize; i >= 0; i-- {
+		if !bytes.Equal(buf[i:i+4], EndOfCentralDirectorySignature[:]) {
+			continue
+		}
+		commentLength := int(buf[i+20]) | int(buf[i+21])<<8
+		if i+minimumSize+commentLength > len(buf) {
+			continue
+		}
+		if err = serial.Unmarshal(bytes.NewReader(buf[i:]), &eocdr); err != nil {
+			return
+		}
+		return eocdr, size - length + int64(i), nil
+	}
+
+	return eocdr, 0, ErrFormat
+}
+
+// findZip64EndOfCentralDirectory follows the ZIP64 locator preceding the end
+// of central directory record, if there is one.
+func findZip64EndOfCentralDirectory(r io.ReaderAt, eocdrOffset int64) (*Zip64EndOfCentralDirectoryRecord, int64, error) {
+	var z64eocdl Zip64EndOfCentralDirectoryLocator
+	var z64eocdr Zip64EndOfCentralDirectoryRecord
+
+	locatorOffset := eocdrOffset - int64(z64eocdl.SizeOf())
+	if locatorOffset < 0 {
+		return nil, 0, nil
+	}
+	if err := serial.Unmarshal(io.NewSectionReader(r, locatorOffset, int64(z64eocdl.SizeOf())), &z64eocdl); err != nil {
+		return nil, 0, err
+	}
+	if z64eocdl.Signature != Zip64EndOfCentralDirectoryLocatorSignature {
+		return nil, 0, nil
+	}
+
+	// the recorded offset is wrong if data was prepended, the record then usually sits right before the locator
+	offsets := []int64{int64(z64eocdl.OffsetOfZip64EndOfCentralDirectoryRecord), locatorOffset - int64(z64eocdr.SizeOf())}
+	for _, offset := range offsets {
+		if offset < 0 || offset > locatorOffset-int64(z64eocdr.SizeOf()) {
+			continue
+		}
+		if err := serial.Unmarshal(io.NewSectionReader(r, offset, int64(z64eocdr.SizeOf())), &z64eocdr); err != nil {
+			return nil, 0, err
+		}
+		if z64eocdr.Signature == Zip64EndOfCentralDirectorySignature {
+			return &z64eocdr, offset, nil
+		}
+	}
+	return nil, 0, ErrFormat
+}
+
+func convertDosTime(date *dos.Date, clock *dos.Time) time.Time {
+	return time.Date(
+		int(date.Year), time.Month(date.Month), int(date.Day),
+		int(clock.Hour), int(clock.Minute), int(clock.Second),
+		0, time.Local,
+	)
+}
+
+// modifiedTime returns the time of the NTFS record of the extra field block,
+// else of the extended timestamp, which are both UTC, else the DOS date and
+// time, taken as local time.
+func modifiedTime(cdh *CentralDirectoryFileHeader) time.Time {
+	var ntfs extrafield.NTFSExtraField
+	if findExtraField(cdh.ExtraField, &ntfs) && ntfs.Mtime != 0 {
+		return extrafield.FiletimeToTime(ntfs.Mtime)
+	}
+	var timestamp extrafield.ExtendedTimestampExtraField
+	if findExtraField(cdh.ExtraField, &timestamp) && !timestamp.ModTime().IsZero() {
+		return timestamp.ModTime()
+	}
+	return convertDosTime(cdh.LastModFileDate, cdh.LastModFileTime)
+}
+
+func newEntry(zr *Reader, cdh *CentralDirectoryFileHeader) (*Entry, error) {
+	compressedSize := uint64(cdh.CompressedSize)
+	uncompressedSize := uint64(cdh.UncompressedSize)
+	offsetOfLocalHeader := uint64(cdh.OffsetOfLocalHeader)
+	if err := readZip64ExtraField(cdh.ExtraField, &uncompressedSize, &compressedSize, &offsetOfLocalHeader); err != nil {
+		return nil, err
+	}
+	if offsetOfLocalHeader > uint64(zr.size) {
+		return nil, ErrFormat
+	}
+
+	e := &Entry{
+		FileHeader: FileHeader{
+			Version:                cdh.Version,
+			VersionNeeded:          cdh.VersionNeeded,
+			Flags:                  cdh.Flags,
+			CompressionMethod:      cdh.CompressionMethod,
+			Modified:               modifiedTime(cdh),
+			CRC32:                  cdh.CRC32,
+			CompressedSize:         compressedSize,
+			UncompressedSize:       uncompressedSize,
+			ExtraField:             cdh.ExtraField,
+			InternalFileAttributes: cdh.InternalFileAttributes,
+			ExternalFileAttributes: cdh.ExternalFileAttributes,
+		},
+		reader:              zr,
+		offsetOfLocalHeader: int64(offsetOfLocalHeader),
+		dosTime:             cdh.LastModFileTime.Get(),
+		rawName:             cdh.FileName,
+		rawComment:          cdh.FileComment,
+	}
+	e.decodeText()
+	return e, nil
+}
+
+func (e *Entry) Open() (io.ReadCloser, error) {
+	offset, err := e.findDataOffset()
+	if err != nil {
+		return nil, err
+	}
+
+	var data io.Reader = io.NewSectionReader(e.reader.r, offset, int64(e.CompressedSize))
+
+	if e.Flags&StrongEncryptionFlag != 0 {
+		return nil, ErrAlgorithm
+	}
+	if e.Flags&EncryptedFlag != 0 && len(e.reader.password) == 0 {
+		return nil, ErrPasswordRequired
+	}
+
+	method := e.CompressionMethod
+	verify := func() error { return nil }
+	omitCRC := false
+	if e.CompressionMethod == CompressionMethodAEx {
+		field, err := readAESExtraField(e.ExtraField)
+		if err != nil {
+			return nil, err
+		}
+		aes, err := winzipaes.NewReader(data, e.reader.password, field.Strength, int64(e.CompressedSize))
+		if errors.Is(err, winzipaes.ErrPassword) {
+			return nil, ErrPassword
+		} else if err != nil {
+			return nil, err
+		}
+		data = aes
+		method = field.CompressionMethod
+		omitCRC = field.Version == extrafield.AESVersion2
+		// decompressors may stop before the authentication code, which is only checked at the end
+		verify = func() error {
+			_, err := io.Copy(io.Discard, aes)
+			return err
+		}
+	} else if e.Flags&EncryptedFlag != 0 {
+		if data, err = zipcrypto.NewReader(data, e.reader.password, e.checkByte()); err != nil {
+			return nil, err
+		}
+	}
+
+	decompress, err := decompressor(method)
+	if err != nil {
+		return nil, err
+	}
+	rc := decompress(data, &e.FileHeader)
+
+	return &checksumReader{rc: rc, entry: e, verify: verify, omitCRC: omitCRC}, nil
+}
+
+// checkByte returns the value that ends the encryption header, the high byte
+// of the CRC-32, or of the time when the CRC-32 follows in a data descriptor.
+func (e *Entry) checkByte() byte {
+	if e.Flags&DataDescriptorFlag != 0 {
+		return byte(e.dosTime >> 8)
+	}
+	return byte(e.CRC32 >> 24)
+}
+
+func (e *Entry) findDataOffset() (int64, error) {
+	offset := e.reader.baseOffset + e.offsetOfLocalHeader
+	if offset < 0 || offset >= e.reader.size {
+		return 0, ErrFormat
+	}
+
+	var lfh LocalFileHeader
+	if err := serial.Unmarshal(io.NewSectionReader(e.reader.r, offset, e.reader.size-offset), &lfh); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, ErrFormat
+		}
+		return 0, err
+	}
+	if lfh.Signature != LocalFileHeaderSignature {
+		return 0, ErrFormat
+	}
+
+	// the local name and extra field may differ from the central ones, only their lengths matter here
+	return offset + int64(lfh.SizeOf()), nil
+}
+
+type checksumReader struct {
+	rc      io.ReadCloser
+	entry   *Entry
+	verify  func() error
+	omitCRC bool
+	crc     uint32
+	read    uint64
+	err     error
+}
+
+func (r *checksumReader) Read(b []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err = r.rc.Read(b)
+	r.crc = crc32.Update(r.crc, b[:n])
+	r.read += uint64(n)
+
+	if r.read > r.entry.UncompressedSize {
+		err = ErrFormat
+	} else if errors.Is(err, io.EOF) {
+		if r.read != r.entry.UncompressedSize {
+			err = io.ErrUnexpectedEOF
+		} else if verifyErr := r.verify(); verifyErr != nil {
+			err = verifyErr
+		} else if !r.omitCRC && r.crc != r.entry.CRC32 {
+			err = &ChecksumError{Name: r.entry.Name, Expected: r.entry.CRC32, Actual: r.crc}
+		}
+	}
+
+	r.err = err
+	return
+}
+
+func (r *checksumReader) Close() error {
+	return r.rc.Close()
+}
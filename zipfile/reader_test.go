@@ -0,0 +1,143 @@
+package zipfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// modified is an even second, which the DOS time of the headers can hold.
+var modified = time.Date(2024, time.May, 17, 10, 30, 42, 0, time.Local)
+
+// writeArchive writes the files, in pairs of name and content, with the given
+// method and a Writer set up by setup, which may be nil.
+func writeArchive(t *testing.T, setup func(*Writer), method uint16, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := NewWriter(&buf)
+	if setup != nil {
+		setup(zw)
+	}
+	for i := 0; i < len(files); i += 2 {
+		w, err := zw.Create(&FileHeader{Name: files[i], CompressionMethod: method, Modified: modified})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(files[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewReader(t *testing.T) {
+	files := []string{"a.txt", "hello, world", "dir/b.txt", string(bytes.Repeat([]byte("text "), 1000))}
+	tests := []struct {
+		name    string
+		method  uint16
+		comment string
+		prefix  []byte
+	}{
+		{"stored", CompressionMethodStored, "", nil},
+		{"deflated", CompressionMethodDeflated, "", nil},
+		{"comment", CompressionMethodDeflated, "archive comment", nil},
+		// as in self-extracting archives, whose offsets ignore the stub
+		{"prefixed", CompressionMethodDeflated, "", bytes.Repeat([]byte{0x90}, 1024)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := writeArchive(t, func(zw *Writer) { zw.SetComment(test.comment) }, test.method, files...)
+			archive = append(test.prefix, archive...)
+
+			zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if zr.Comment != test.comment {
+				t.Errorf("comment %q, want %q", zr.Comment, test.comment)
+			}
+			if len(zr.Entries) != len(files)/2 {
+				t.Fatalf("%d entries, want %d", len(zr.Entries), len(files)/2)
+			}
+			for i, e := range zr.Entries {
+				name, content := files[2*i], files[2*i+1]
+				if e.Name != name || e.CompressionMethod != test.method {
+					t.Errorf("entry %q method %d, want %q method %d", e.Name, e.CompressionMethod, name, test.method)
+				}
+				if e.UncompressedSize != uint64(len(content)) || e.CRC32 != crc32.Checksum([]byte(content)) {
+					t.Errorf("%s: size %d CRC-32 %08x, want %d %08x",
+						e.Name, e.UncompressedSize, e.CRC32, len(content), crc32.Checksum([]byte(content)))
+				}
+				if !e.Modified.Equal(modified) {
+					t.Errorf("%s: modified %v, want %v", e.Name, e.Modified, modified)
+				}
+			}
+
+			contents := readAll(t, archive, "")
+			for i := 0; i < len(files); i += 2 {
+				if got := contents[filepath.Base(files[i])]; got != files[i+1] {
+					t.Errorf("%s: content %.20q, want %.20q", files[i], got, files[i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	archive := writeArchive(t, nil, CompressionMethodStored, "a.txt", "hello, world")
+	tests := map[string][]byte{
+		"empty":         nil,
+		"not a zip":     bytes.Repeat([]byte("not a zip file "), 10),
+		"truncated end": archive[:len(archive)-10],
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrFormat) {
+				t.Errorf("error %v, want %v", err, ErrFormat)
+			}
+		})
+	}
+}
+
+func TestOpenChecksum(t *testing.T) {
+	content := string(bytes.Repeat([]byte("text "), 1000))
+	tests := []struct {
+		name   string
+		method uint16
+	}{
+		{"stored", CompressionMethodStored},
+		{"deflated", CompressionMethodDeflated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := writeArchive(t, nil, test.method, "a.txt", content)
+			zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := zr.Entries[0]
+			want := e.CRC32
+			e.CRC32 ^= 1
+
+			rc, err := e.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			_, err = io.ReadAll(rc)
+			var checksumErr *ChecksumError
+			if !errors.As(err, &checksumErr) {
+				t.Fatalf("error %v, want a checksum error", err)
+			}
+			if checksumErr.Name != "a.txt" || checksumErr.Expected != e.CRC32 || checksumErr.Actual != want {
+				t.Errorf("checksum error %+v, want %08x read for %08x", checksumErr, want, e.CRC32)
+			}
+		})
+	}
+}
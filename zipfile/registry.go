@@ -0,0 +1,105 @@
+package zipfile
+
+import (
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compressor returns a writer compressing into w. The level is the one set on
+// the archive or the entry, whose meaning depends on the method.
+type Compressor func(w io.Writer, level int) (io.WriteCloser, error)
+
+// CompressorOptions are the settings of the archive handed to the compressor
+// of an entry.
+type CompressorOptions struct {
+	Level int
+	// Concurrency is how many goroutines may encode the entry, one if zero,
+	// for the methods whose encoder can use several.
+	Concurrency int
+}
+
+// OptionsCompressor is a Compressor for methods that take settings beyond the
+// level, such as their concurrency.
+type OptionsCompressor func(w io.Writer, options CompressorOptions) (io.WriteCloser, error)
+
+// Decompressor returns a reader decompressing r.
+type Decompressor func(r io.Reader) io.ReadCloser
+
+// HeaderDecompressor is a Decompressor for methods that take parameters from
+// the header of the entry, such as its flags or its uncompressed size.
+type HeaderDecompressor func(r io.Reader, header *FileHeader) io.ReadCloser
+
+var (
+	compressors   sync.Map // map[uint16]OptionsCompressor
+	decompressors sync.Map // map[uint16]HeaderDecompressor
+)
+
+func init() {
+	RegisterCompressor(CompressionMethodStored, func(w io.Writer, level int) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	})
+	RegisterCompressor(CompressionMethodDeflated, func(w io.Writer, level int) (io.WriteCloser, error) {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return nil, fmt.Errorf("invalid deflate level %d", level)
+		}
+		return flate.NewWriter(w, level)
+	})
+
+	RegisterDecompressor(CompressionMethodStored, io.NopCloser)
+	RegisterDecompressor(CompressionMethodDeflated, flate.NewReader)
+}
+
+// RegisterCompressor makes a compression method available to Zip and Writer.
+// Registering a method twice panics.
+func RegisterCompressor(method uint16, compressor Compressor) {
+	RegisterOptionsCompressor(method, func(w io.Writer, options CompressorOptions) (io.WriteCloser, error) {
+		return compressor(w, options.Level)
+	})
+}
+
+// RegisterOptionsCompressor makes a compression method available to Zip and
+// Writer. Registering a method twice panics.
+func RegisterOptionsCompressor(method uint16, compressor OptionsCompressor) {
+	if _, loaded := compressors.LoadOrStore(method, compressor); loaded {
+		panic(fmt.Sprintf("zip: compressor already registered for method %d", method))
+	}
+}
+
+// RegisterDecompressor makes a compression method available to Reader.
+// Registering a method twice panics.
+func RegisterDecompressor(method uint16, decompressor Decompressor) {
+	RegisterHeaderDecompressor(method, func(r io.Reader, header *FileHeader) io.ReadCloser {
+		return decompressor(r)
+	})
+}
+
+// RegisterHeaderDecompressor makes a compression method available to Reader.
+// Registering a method twice panics.
+func RegisterHeaderDecompressor(method uint16, decompressor HeaderDecompressor) {
+	if _, loaded := decompressors.LoadOrStore(method, decompressor); loaded {
+		panic(fmt.Sprintf("zip: decompressor already registered for method %d", method))
+	}
+}
+
+func compressor(method uint16) (OptionsCompressor, error) {
+	if c, ok := compressors.Load(method); ok {
+		return c.(OptionsCompressor), nil
+	}
+	return nil, unsupportedMethod(method)
+}
+
+func decompressor(method uint16) (HeaderDecompressor, error) {
+	if d, ok := decompressors.Load(method); ok {
+		return d.(HeaderDecompressor), nil
+	}
+	return nil, unsupportedMethod(method)
+}
+
+func unsupportedMethod(method uint16) error {
+	if name, ok := MapOfCompressionMethod[method]; ok {
+		return fmt.Errorf("%w: %s (method %d)", ErrAlgorithm, name, method)
+	}
+	return fmt.Errorf("%w: method %d", ErrAlgorithm, method)
+}
@@ -0,0 +1,46 @@
+package zipfile
+
+import (
+	"io"
+)
+
+type countWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (w *countWriter) Write(b []byte) (n int, err error) {
+	n, err = w.w.Write(b)
+	w.count += int64(n)
+	return
+}
+
+type checksumWriter struct {
+	crc uint32
+}
+
+func (w *checksumWriter) Write(b []byte) (int, error) {
+	w.crc = crc32.Update(w.crc, b)
+	return len(b), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// errorReadCloser stands for a decompressor that could not be set up.
+type errorReadCloser struct {
+	err error
+}
+
+func (r errorReadCloser) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func (r errorReadCloser) Close() error {
+	return nil
+}
@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"compress/flate"
 	"errors"
+	"fmt"
 	"go-zipfile/crc"
 	"go-zipfile/serial"
 	"go-zipfile/zipfile/dos"
+	"go-zipfile/zipfile/extrafield"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
-
-	"golang.org/x/sys/windows"
 )
 
 var crc32 *crc.CyclicRedundancyCheck32
@@ -22,125 +24,132 @@ func init() {
 
 type FileEntry struct {
 	FilePath          string
-	CreationTime      windows.Filetime
-	LastAccessTime    windows.Filetime
-	LastWriteTime     windows.Filetime
+	CreationTime      time.Time
+	LastAccessTime    time.Time
+	LastWriteTime     time.Time
+	VersionMadeBy     uint8
 	FileAttributes    uint32
-	FileSize          uint32
+	Uid               uint32
+	Gid               uint32
+	FileSize          uint64
 	CRC32             uint32
-	DataSize          uint32
+	DataSize          uint64
 	Data              FileData
 	CompressionMethod uint16
+	CompressionLevel  int
 }
 
 func (e *FileEntry) Deflate(level int) (err error) {
-	if e.FileSize < 16 {
-		return
-	}
-
-	var buf bytes.Buffer
-
-	writer, err := flate.NewWriter(&buf, level)
-	if err != nil {
-		return
-	}
+	return e.Compress(CompressionMethodDeflated, level)
+}
 
-	if _, err = writer.Write(e.Data); err != nil {
+// Compress selects a registered compression method for the entry.
+func (e *FileEntry) Compress(method uint16, level int) (err error) {
+	if _, err = compressor(method); err != nil {
 		return
 	}
 
-	if err = writer.Close(); err != nil {
+	if e.FileSize < 16 {
 		return
 	}
 
-	e.CompressionMethod = CompressionMethodDeflated
-	e.Data = buf.Bytes()
-	e.DataSize = uint32(len(e.Data))
+	// the data itself is only compressed while the archive is written
+	e.CompressionMethod = method
+	e.CompressionLevel = level
 
 	return
 }
 
-func unixNanoseconds(nanoseconds int64) time.Time {
-	return time.Unix(nanoseconds/int64(time.Second), nanoseconds%int64(time.Second))
+func convertTime(t time.Time) (*dos.Date, *dos.Time) {
+	t = t.Local()
+	if t.Year() < 1980 {
+		// the earliest moment a DOS date can hold
+		t = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.Local)
+	}
+	return &dos.Date{
+		Year:  uint16(t.Year()),
+		Month: uint16(t.Month()),
+		Day:   uint16(t.Day()),
+	}, &dos.Time{
+		Hour:   uint16(t.Hour()),
+		Minute: uint16(t.Minute()),
+		Second: uint16(t.Second()),
+	}
 }
 
-func convertFiletime(filetime windows.Filetime) (*dos.Date, *dos.Time) {
-	unix := unixNanoseconds(filetime.Nanoseconds())
-	return &dos.Date{
-			Year:  uint16(unix.Year()),
-			Month: uint16(unix.Month()),
-			Day:   uint16(unix.Day()),
-		}, &dos.Time{
-			Hour:   uint16(unix.Hour()),
-			Minute: uint16(unix.Minute()),
-			Second: uint16(unix.Second()),
-		}
+// SymlinkPolicy tells how Zip.Add handles symbolic links.
+type SymlinkPolicy uint8
+
+const (
+	// SymlinkStore adds links as entries holding their target, Info-ZIP style.
+	SymlinkStore SymlinkPolicy = iota
+	// SymlinkFollow adds the file or directory links point to in their place.
+	SymlinkFollow
+	// SymlinkSkip leaves links out.
+	SymlinkSkip
+)
+
+// NewFileEntry returns an entry for the file at path, which holds the target
+// of the link if the file is a symbolic link.
+func NewFileEntry(path string) (*FileEntry, error) {
+	return newFileEntry(path, false)
 }
 
-func NewFileEntry(path string) *FileEntry {
+func newFileEntry(path string, follow bool) (*FileEntry, error) {
 	entry := &FileEntry{
-		FilePath: strings.ReplaceAll(path, "\\", "/"),
-	}
-
-	stat, err := os.Stat(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
-			return nil
-		}
+		FilePath: filepath.ToSlash(path),
 	}
 
-	attrs, err := windows.GetFileAttributes(windows.StringToUTF16Ptr(path))
+	// platform specific metadata, see types_windows.go and types_unix.go
+	stat, err := entry.stat(path, follow)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	entry.FileAttributes = attrs
-
-	handle, err := windows.CreateFile(
-		windows.StringToUTF16Ptr(path),
-		windows.GENERIC_READ,
-		windows.FILE_SHARE_READ,
-		nil,
-		windows.OPEN_EXISTING,
-		windows.FILE_ATTRIBUTE_NORMAL|windows.FILE_FLAG_BACKUP_SEMANTICS,
-		0,
-	)
-
-	var createTime, accessTime, writeTime windows.Filetime
-	if err = windows.GetFileTime(handle, &createTime, &accessTime, &writeTime); err != nil {
-		return nil
-	}
-
-	entry.CreationTime = createTime
-	entry.LastAccessTime = accessTime
-	entry.LastWriteTime = writeTime
 
 	if stat.IsDir() {
-		return entry
+		return entry, nil
 	}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil
+	if stat.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		target = filepath.ToSlash(target)
+		entry.Data = NewFileDataAt(strings.NewReader(target), 0, int64(len(target)))
+		entry.FileSize = uint64(len(target))
+		entry.CompressionMethod = CompressionMethodStored
+		return entry, nil
 	}
-	entry.CRC32 = crc32.Checksum(data)
-	entry.Data = data
-	entry.FileSize = uint32(stat.Size())
-	entry.DataSize = entry.FileSize
+
+	entry.Data = NewFileData(path, 0, stat.Size())
+	entry.FileSize = uint64(stat.Size())
 	entry.CompressionMethod = CompressionMethodStored
 
-	return entry
+	return entry, nil
 }
 
 type Zip struct {
-	CompressionMethod uint16
-	CompressionLevel  int
-	FileEntries       []*FileEntry
+	CompressionMethod  uint16
+	CompressionLevel   int
+	Password           string
+	Encryption         uint8
+	Workers            int
+	EncoderConcurrency int
+	MemoryBudget       int64
+	Policy             *CompressionPolicy
+	Symlinks           SymlinkPolicy
+	FileEntries        []*FileEntry
 }
 
 func NewZip() *Zip {
 	return &Zip{
-		CompressionMethod: CompressionMethodStored,
-		CompressionLevel:  flate.DefaultCompression,
+		CompressionMethod:  CompressionMethodStored,
+		CompressionLevel:   flate.DefaultCompression,
+		Workers:            1,
+		EncoderConcurrency: 1,
+		MemoryBudget:       DefaultMemoryBudget,
+		Policy:             &CompressionPolicy{},
 	}
 }
 
@@ -152,99 +161,419 @@ func (z *Zip) SetDeflateLevel(level int) {
 	z.CompressionLevel = level
 }
 
+// SetCompressionLevel sets the level handed to the compressor of any method.
+func (z *Zip) SetCompressionLevel(level int) {
+	z.CompressionLevel = level
+}
+
+// SetPassword encrypts the entries with the method chosen by SetEncryption.
+// An empty password disables it.
+func (z *Zip) SetPassword(password string) {
+	z.Password = password
+}
+
+// SetEncryption chooses how entries are encrypted when a password is set. The
+// default, EncryptionZipCrypto, only keeps casual readers away; the AES methods
+// are the ones to use for anything sensitive.
+func (z *Zip) SetEncryption(method uint8) {
+	z.Encryption = method
+}
+
+// SetSymlinkPolicy chooses how Add handles symbolic links, SymlinkStore by default.
+func (z *Zip) SetSymlinkPolicy(policy SymlinkPolicy) {
+	z.Symlinks = policy
+}
+
 func (z *Zip) Add(path string) (err error) {
-	entry := NewFileEntry(path)
+	if z.Symlinks == SymlinkSkip {
+		stat, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if stat.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+	}
+
+	entry, err := newFileEntry(path, z.Symlinks == SymlinkFollow)
+	if err != nil {
+		return
+	}
 
-	switch z.CompressionMethod {
-	case CompressionMethodStored:
-	case CompressionMethodDeflated:
-		if err = entry.Deflate(z.CompressionLevel); err != nil {
+	if err = entry.Compress(z.CompressionMethod, z.CompressionLevel); err != nil {
+		return
+	}
+	if z.Policy != nil {
+		if err = z.Policy.apply(entry); err != nil {
 			return
 		}
-	default:
 	}
 
 	z.FileEntries = append(z.FileEntries, entry)
 	return
 }
 
-func (z *Zip) Build() (FileFormat, error) {
-	ff := FileFormat{}
+func (e *FileEntry) fileHeader() *FileHeader {
+	fh := &FileHeader{
+		Name:                   e.FilePath,
+		Version:                uint16(e.VersionMadeBy)<<8 | LatestVersion,
+		CompressionMethod:      e.CompressionMethod,
+		Modified:               e.LastWriteTime,
+		CRC32:                  e.CRC32,
+		CompressedSize:         e.DataSize,
+		UncompressedSize:       e.FileSize,
+		ExternalFileAttributes: e.FileAttributes,
+	}
+	fh.ExtraField = e.extraField()
+	fh.Flags = compressionFlags(fh.CompressionMethod) | fh.encodingFlags()
+	fh.VersionNeeded = fh.minimumVersion()
+	return fh
+}
 
-	var offset, cdhSize uint32
+// extraField returns the records of the metadata the headers cannot hold. The
+// DOS time only has a 2-second precision, in a local time of unknown zone.
+func (e *FileEntry) extraField() []byte {
+	var fields extrafield.Fields
+	if !e.LastWriteTime.IsZero() {
+		fields = append(fields, extrafield.NewNTFSExtraField(e.LastWriteTime, e.LastAccessTime, e.CreationTime))
+		if timestamp := extrafield.NewExtendedTimestampExtraField(e.LastWriteTime, e.LastAccessTime); timestamp != nil {
+			fields = append(fields, timestamp)
+		}
+	}
+	if e.VersionMadeBy == VersionMadeByUNIX {
+		fields = append(fields, extrafield.NewInfoZIPUnixExtraField(e.Uid, e.Gid))
+	}
+	extra, _ := extrafield.Serialize(fields)
+	return extra
+}
 
+// writeData streams the entry from its source through the compressor and the
+// encryption if any, then records the checksum and both sizes of what was
+// actually written. It returns the size of the compressed data before
+// encryption.
+func (e *FileEntry) writeData(writer io.Writer, fh *FileHeader, concurrency int, password []byte) (payload int64, err error) {
+	src, err := e.Data.Open()
+	if err != nil {
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	fw, err := newFileWriter(writer, fh, CompressorOptions{Level: e.CompressionLevel, Concurrency: concurrency}, password)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(fw, src); err != nil {
+		return
+	}
+	if err = fw.close(); err != nil {
+		return
+	}
+
+	e.update(fh)
+	return fw.payload.count, nil
+}
+
+// update records the checksum and sizes of the data written for the entry.
+func (e *FileEntry) update(fh *FileHeader) {
+	e.CRC32 = fh.CRC32
+	e.FileSize = fh.UncompressedSize
+	e.DataSize = fh.CompressedSize
+}
+
+// patchLocalFileHeader rewrites a local file header written before its data,
+// which keeps its size, then returns to the current position.
+func patchLocalFileHeader(writer io.WriteSeeker, offset int64, lfh LocalFileHeader) (err error) {
+	current, err := writer.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+
+	if _, err = writer.Seek(offset, io.SeekStart); err != nil {
+		return
+	}
+	if err = serial.Marshal(writer, lfh); err != nil {
+		return
+	}
+
+	_, err = writer.Seek(current, io.SeekStart)
+	return
+}
+
+var errBuildZip64 = errors.New("zip: archive needs ZIP64 records, which only Marshal writes")
+
+// Build returns the records of the archive. Stored entries keep referring to
+// their source, which is read again when the records are marshalled, while
+// the others are compressed, and encrypted if a password is set, into memory,
+// since their headers hold the size of the result.
+func (z *Zip) Build() (ff FileFormat, err error) {
+	var offset, cdhSize uint64
 	for _, entry := range z.FileEntries {
-		LastModFileTime, LastModFileDate := convertFiletime(entry.LastWriteTime)
-		FileNameLength := uint16(len(entry.FilePath))
-		FileName := []byte(entry.FilePath)
-
-		lfh := LocalFileHeader{
-			Signature:         LocalFileHeaderSignature,
-			Version:           DefaultVersion,
-			Flags:             0,
-			CompressionMethod: entry.CompressionMethod,
-			LastModFileTime:   LastModFileDate,
-			LastModFileDate:   LastModFileTime,
-			CRC32:             entry.CRC32,
-			CompressedSize:    entry.DataSize,
-			UncompressedSize:  entry.FileSize,
-			FileNameLength:    FileNameLength,
-			ExtraFieldLength:  0,
-			FileName:          FileName,
-			ExtraField:        nil,
+		var record LocalFileRecord
+		var cdh CentralDirectoryFileHeader
+		if record, cdh, err = z.buildEntry(entry, offset); err != nil {
+			return
 		}
+		ff.LocalFileRecords = append(ff.LocalFileRecords, record)
+		ff.CentralDirectoryRecord.CentralDirectoryHeaders = append(ff.CentralDirectoryRecord.CentralDirectoryHeaders, cdh)
 
-		ff.LocalFileRecords = append(ff.LocalFileRecords, LocalFileRecord{
-			LocalFileHeader: lfh,
-			FileData:        entry.Data,
-		})
-
-		cdh := CentralDirectoryFileHeader{
-			Signature:              CentralFileHeaderSignature,
-			Version:                LatestVersion,
-			VersionNeeded:          DefaultVersion,
-			Flags:                  0,
-			CompressionMethod:      entry.CompressionMethod,
-			LastModFileTime:        LastModFileDate,
-			LastModFileDate:        LastModFileTime,
-			CRC32:                  entry.CRC32,
-			CompressedSize:         entry.DataSize,
-			UncompressedSize:       entry.FileSize,
-			FileNameLength:         FileNameLength,
-			ExtraFieldLength:       0,
-			FileCommentLength:      0,
-			DiskNumberStart:        0,
-			InternalFileAttributes: 0,
-			ExternalFileAttributes: entry.FileAttributes,
-			OffsetOfLocalHeader:    offset,
-			FileName:               FileName,
-			ExtraField:             nil,
-			FileComment:            nil,
+		offset += uint64(record.LocalFileHeader.SizeOf()) + uint64(record.FileData.Len())
+		if record.DataDescriptor != nil {
+			offset += 12
 		}
-		ff.CentralDirectoryRecord.CentralDirectoryHeaders = append(ff.CentralDirectoryRecord.CentralDirectoryHeaders, cdh)
-		offset += lfh.SizeOf() + entry.DataSize
-		cdhSize += cdh.SizeOf()
+		cdhSize += uint64(cdh.SizeOf())
+	}
+	if len(z.FileEntries) >= 0xffff || offset >= uint32max || cdhSize >= uint32max {
+		return ff, errBuildZip64
 	}
 
 	TotalEntries := uint16(len(z.FileEntries))
 	ff.EndOfCentralDirectoryRecord = EndOfCentralDirectoryRecord{
 		Signature:                  EndOfCentralDirectorySignature,
-		DiskNumber:                 0,
-		StartingDiskNumber:         0,
 		DiskTotalEntries:           TotalEntries,
 		TotalEntries:               TotalEntries,
-		CentralDirectorySize:       cdhSize,
-		OffsetOfStartingDiskNumber: offset,
-		ZIPFileCommentLength:       0,
-		ZIPFileComment:             nil,
+		CentralDirectorySize:       uint32(cdhSize),
+		OffsetOfStartingDiskNumber: uint32(offset),
+	}
+	return
+}
+
+// buildEntry returns the records of an entry written at offset.
+func (z *Zip) buildEntry(entry *FileEntry, offset uint64) (record LocalFileRecord, cdh CentralDirectoryFileHeader, err error) {
+	fh, err := z.fileHeader(entry)
+	if err != nil {
+		return
 	}
-	return ff, nil
+	stored := fh.CompressionMethod == CompressionMethodStored && fh.Flags&EncryptedFlag == 0
+
+	// stored data is only read for its checksum
+	var output bytes.Buffer
+	var writer io.Writer = &output
+	if stored {
+		writer = io.Discard
+	}
+	payload, err := entry.writeData(writer, fh, z.EncoderConcurrency, []byte(z.Password))
+	if err != nil {
+		return
+	}
+	if z.Policy != nil && entry.CompressionMethod != CompressionMethodStored && !z.Policy.worthwhile(payload, int64(entry.FileSize)) {
+		entry.CompressionMethod = CompressionMethodStored
+		return z.buildEntry(entry, offset)
+	}
+	if fh.isZip64() || offset >= uint32max {
+		return record, cdh, errBuildZip64
+	}
+
+	record.LocalFileHeader = fh.localFileHeader(false)
+	record.FileData = NewFileDataAt(bytes.NewReader(output.Bytes()), 0, int64(output.Len()))
+	if stored {
+		record.FileData = entry.Data
+	}
+	if fh.Flags&DataDescriptorFlag != 0 {
+		record.DataDescriptor = &DataDescriptor{
+			CRC32:            fh.CRC32,
+			CompressedSize:   uint32(fh.CompressedSize),
+			UncompressedSize: uint32(fh.UncompressedSize),
+		}
+	}
+	return record, fh.centralDirectoryFileHeader(offset), nil
+}
+
+// Marshal writes the archive, reading and compressing each entry from its
+// source only now, so memory use does not depend on the size of the entries.
+// With more than one worker, see SetWorkers, entries are compressed in
+// parallel.
+func (z *Zip) Marshal(writer io.WriteSeeker) (err error) {
+	m := &marshaller{zip: z, writer: writer}
+	if m.start, err = writer.Seek(0, io.SeekCurrent); err != nil {
+		return
+	}
+
+	if z.Workers > 1 {
+		if err = m.writeParallel(); err != nil {
+			return
+		}
+	} else {
+		for _, entry := range z.FileEntries {
+			if err = m.writeEntry(entry); err != nil {
+				return
+			}
+		}
+	}
+
+	return m.writeCentralDirectory()
+}
+
+// marshaller follows the entries Marshal has written.
+type marshaller struct {
+	zip     *Zip
+	writer  io.WriteSeeker
+	start   int64
+	offset  uint64
+	end     int64
+	headers []CentralDirectoryFileHeader
+}
+
+func (m *marshaller) writeEntry(entry *FileEntry) (err error) {
+	fh, zip64, err := m.begin(entry)
+	if err != nil {
+		return
+	}
+	payload, err := entry.writeData(m.writer, fh, m.zip.EncoderConcurrency, []byte(m.zip.Password))
+	if err != nil {
+		return
+	}
+	if !m.worthwhile(entry, payload) {
+		return m.rewriteStored(entry)
+	}
+	return m.finish(entry, fh, zip64)
+}
+
+// worthwhile tells whether the compressed data of an entry is kept by the
+// policy of the archive.
+func (m *marshaller) worthwhile(entry *FileEntry, payload int64) bool {
+	policy := m.zip.Policy
+	if policy == nil || entry.CompressionMethod == CompressionMethodStored {
+		return true
+	}
+	return policy.worthwhile(payload, int64(entry.FileSize))
+}
+
+// rewriteStored writes the current entry again as stored over its compressed
+// data, which did not save MinSaving of its size. The stored copy is longer
+// than the compressed data when compressing saved less than that but more
+// than nothing.
+func (m *marshaller) rewriteStored(entry *FileEntry) (err error) {
+	if err = m.mark(); err != nil {
+		return
+	}
+	if _, err = m.writer.Seek(m.start+int64(m.offset), io.SeekStart); err != nil {
+		return
+	}
+	entry.CompressionMethod = CompressionMethodStored
+	return m.writeEntry(entry)
+}
+
+// mark records how far the archive has been written.
+func (m *marshaller) mark() (err error) {
+	current, err := m.writer.Seek(0, io.SeekCurrent)
+	m.end = max(m.end, current)
+	return
+}
+
+// writeCentralDirectory ends the archive. An entry rewritten as stored may
+// have left bytes behind the last one, which are cut off if the writer can be
+// truncated, like a file, or else kept before the central directory.
+func (m *marshaller) writeCentralDirectory() (err error) {
+	current, err := m.writer.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	truncater, truncatable := m.writer.(interface{ Truncate(size int64) error })
+	if current < m.end && !truncatable {
+		if current, err = m.writer.Seek(m.end, io.SeekStart); err != nil {
+			return
+		}
+		m.offset = uint64(current - m.start)
+	}
+
+	if err = writeCentralDirectory(m.writer, m.headers, m.offset, ""); err != nil {
+		return
+	}
+
+	if current, err = m.writer.Seek(0, io.SeekCurrent); err != nil {
+		return
+	}
+	if current < m.end && truncatable {
+		err = truncater.Truncate(current)
+	}
+	return
 }
 
-func (z *Zip) Marshal(file *os.File) (err error) {
-	var ff FileFormat
-	if ff, err = z.Build(); err != nil {
+// begin writes the local header of an entry ahead of its data.
+func (m *marshaller) begin(entry *FileEntry) (fh *FileHeader, zip64 bool, err error) {
+	// room for ZIP64 sizes has to be made before the data is written
+	zip64 = mayNeedZip64(entry.FileSize)
+
+	if fh, err = m.zip.fileHeader(entry); err != nil {
 		return
 	}
-	return serial.Marshal(file, ff)
+	err = serial.Marshal(m.writer, fh.localFileHeader(zip64))
+	return
+}
+
+// finish completes an entry once its data is written.
+func (m *marshaller) finish(entry *FileEntry, fh *FileHeader, zip64 bool) (err error) {
+	if fh.isZip64() && !zip64 {
+		return fmt.Errorf("%s grew past 4 GiB while being archived", entry.FilePath)
+	}
+	if fh.Flags&DataDescriptorFlag != 0 {
+		if err = writeDataDescriptor(m.writer, fh, zip64, true); err != nil {
+			return
+		}
+	}
+
+	lfh := fh.localFileHeader(zip64)
+	if err = patchLocalFileHeader(m.writer, m.start+int64(m.offset), lfh); err != nil {
+		return
+	}
+	m.headers = append(m.headers, fh.centralDirectoryFileHeader(m.offset))
+
+	current, err := m.writer.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	m.offset = uint64(current - m.start)
+	return
+}
+
+// fileHeader returns the header of an entry as this archive writes it.
+func (z *Zip) fileHeader(entry *FileEntry) (*FileHeader, error) {
+	fh := entry.fileHeader()
+	if len(z.Password) == 0 || fh.IsDir() {
+		return fh, nil
+	}
+
+	if err := fh.encrypt(z.Encryption, entry.FileSize); err != nil {
+		return nil, err
+	}
+	if z.Encryption == EncryptionZipCrypto {
+		// the check byte of the encryption header is taken from the time, since
+		// the CRC-32 is only known afterward, which needs a data descriptor
+		fh.Flags |= DataDescriptorFlag
+	}
+	fh.VersionNeeded = fh.minimumVersion()
+	return fh, nil
+}
+
+// WriteTo writes the archive to a writer that cannot seek, such as a pipe,
+// with data descriptors in place of patched local headers.
+func (z *Zip) WriteTo(writer io.Writer) (n int64, err error) {
+	counter := &countWriter{w: writer}
+	defer func() { n = counter.count }()
+
+	zw := NewWriter(counter)
+	zw.SetPassword(z.Password)
+	zw.SetEncryption(z.Encryption)
+	zw.EncoderConcurrency = z.EncoderConcurrency
+	for _, entry := range z.FileEntries {
+		zw.SetCompressionLevel(entry.CompressionLevel)
+
+		var w io.Writer
+		if w, err = zw.Create(entry.fileHeader()); err != nil {
+			return
+		}
+
+		var src io.ReadCloser
+		if src, err = entry.Data.Open(); err != nil {
+			return
+		}
+		_, err = io.Copy(w, src)
+		_ = src.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	err = zw.Close()
+	return
 }
@@ -0,0 +1,57 @@
+//go:build unix
+
+package zipfile
+
+import (
+	"go-zipfile/zipfile/dos"
+	"go-zipfile/zipfile/posix"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func timespecToTime(ts unix.Timespec) time.Time {
+	return time.Unix(ts.Unix())
+}
+
+func (e *FileEntry) stat(path string, follow bool) (os.FileInfo, error) {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var st unix.Stat_t
+	if err = unix.Lstat(path, &st); err != nil {
+		return nil, err
+	}
+	followed := follow && st.Mode&unix.S_IFMT == unix.S_IFLNK
+	if followed {
+		if stat, err = os.Stat(path); err != nil {
+			return nil, err
+		}
+		if err = unix.Stat(path, &st); err != nil {
+			return nil, err
+		}
+	}
+
+	mode := uint16(st.Mode)
+	attrs := uint32(mode) << 16
+	if stat.IsDir() {
+		attrs |= dos.FileAttributeDirectory
+	}
+	if mode&posix.StatIsWriteableUser == 0 {
+		attrs |= dos.FileAttributeReadonly
+	}
+	e.VersionMadeBy = VersionMadeByUNIX
+	e.FileAttributes = attrs
+	e.Uid = st.Uid
+	e.Gid = st.Gid
+
+	// zero when the system or the file system keeps none, see birthtime_*.go
+	e.CreationTime = birthTime(path, &st, followed)
+	e.LastAccessTime = timespecToTime(st.Atim)
+	e.LastWriteTime = timespecToTime(st.Mtim)
+
+	return stat, nil
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package zipfile
+
+import (
+	"go-zipfile/zipfile/posix"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func filetimeToTime(filetime windows.Filetime) time.Time {
+	return time.Unix(0, filetime.Nanoseconds())
+}
+
+func (e *FileEntry) stat(path string, follow bool) (os.FileInfo, error) {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := windows.GetFileAttributes(windows.StringToUTF16Ptr(path))
+	if err != nil {
+		return nil, err
+	}
+	e.VersionMadeBy = VersionMadeByMS_DOS_and_OS_2
+	e.FileAttributes = attrs
+
+	flags := uint32(windows.FILE_ATTRIBUTE_NORMAL | windows.FILE_FLAG_BACKUP_SEMANTICS)
+	if stat.Mode()&os.ModeSymlink != 0 {
+		if follow {
+			if stat, err = os.Stat(path); err != nil {
+				return nil, err
+			}
+			e.FileAttributes &^= windows.FILE_ATTRIBUTE_REPARSE_POINT
+		} else {
+			// links are only told apart by their POSIX mode, as Info-ZIP stores them
+			e.VersionMadeBy = VersionMadeByUNIX
+			e.FileAttributes |= uint32(posix.StatIsSymbolicLink|0o777) << 16
+			flags |= windows.FILE_FLAG_OPEN_REPARSE_POINT
+		}
+	}
+
+	handle, err := windows.CreateFile(
+		windows.StringToUTF16Ptr(path),
+		windows.GENERIC_READ,
+		windows.FILE_SHARE_READ,
+		nil,
+		windows.OPEN_EXISTING,
+		flags,
+		0,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = windows.CloseHandle(handle) }()
+
+	var createTime, accessTime, writeTime windows.Filetime
+	if err = windows.GetFileTime(handle, &createTime, &accessTime, &writeTime); err != nil {
+		return nil, err
+	}
+
+	e.CreationTime = filetimeToTime(createTime)
+	e.LastAccessTime = filetimeToTime(accessTime)
+	e.LastWriteTime = filetimeToTime(writeTime)
+
+	return stat, nil
+}
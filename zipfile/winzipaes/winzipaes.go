@@ -0,0 +1,231 @@
+// Package winzipaes implements the AES encryption of WinZip, which derives its
+// keys with PBKDF2, encrypts with AES in counter mode and authenticates the
+// encrypted data with HMAC-SHA1.
+package winzipaes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"errors"
+	"hash"
+	"io"
+)
+
+const (
+	Strength128 uint8 = iota + 1
+	Strength192
+	Strength256
+)
+
+const (
+	verifierSize   = 2
+	authCodeSize   = 10
+	iterationCount = 1000
+)
+
+var (
+	ErrPassword       = errors.New("zip: invalid password")
+	ErrAuthentication = errors.New("zip: authentication code mismatch")
+	ErrStrength       = errors.New("zip: invalid AES strength")
+	errTruncated      = errors.New("zip: encrypted data too short")
+)
+
+func keySize(strength uint8) int {
+	switch strength {
+	case Strength128:
+		return 16
+	case Strength192:
+		return 24
+	case Strength256:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// Overhead returns the number of bytes the encryption adds to the data:
+// the salt, the password verifier and the authentication code.
+func Overhead(strength uint8) int {
+	return keySize(strength)/2 + verifierSize + authCodeSize
+}
+
+// deriveKeys returns the encryption key, the authentication key and the
+// password verifier derived from the password and the salt.
+func deriveKeys(password, salt []byte, size int) (cipher.Block, hash.Hash, []byte, error) {
+	key, err := pbkdf2.Key(sha1.New, string(password), salt, iterationCount, 2*size+verifierSize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	block, err := aes.NewCipher(key[:size])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return block, hmac.New(sha1.New, key[size:2*size]), key[2*size:], nil
+}
+
+// ctr is AES in counter mode as WinZip does it, with a little-endian counter
+// starting at 1, unlike cipher.NewCTR.
+type ctr struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+func newCTR(block cipher.Block) *ctr {
+	return &ctr{block: block, used: aes.BlockSize}
+}
+
+func (c *ctr) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.used == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			c.used = 0
+		}
+		dst[i] = src[i] ^ c.stream[c.used]
+		c.used++
+	}
+}
+
+type writer struct {
+	w    io.Writer
+	ctr  *ctr
+	mac  hash.Hash
+	buf  []byte
+	done bool
+}
+
+// NewWriter writes the salt and the password verifier, then encrypts
+// everything written to the returned writer. Close appends the
+// authentication code and does not close w.
+func NewWriter(w io.Writer, password []byte, strength uint8) (io.WriteCloser, error) {
+	size := keySize(strength)
+	if size == 0 {
+		return nil, ErrStrength
+	}
+
+	salt := make([]byte, size/2)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	block, mac, verifier, err := deriveKeys(password, salt, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(salt); err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(verifier); err != nil {
+		return nil, err
+	}
+	return &writer{w: w, ctr: newCTR(block), mac: mac}, nil
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	w.buf = append(w.buf[:0], b...)
+	w.ctr.XORKeyStream(w.buf, w.buf)
+	// the authentication code covers the encrypted data
+	w.mac.Write(w.buf)
+	return w.w.Write(w.buf)
+}
+
+func (w *writer) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	_, err := w.w.Write(w.mac.Sum(nil)[:authCodeSize])
+	return err
+}
+
+type reader struct {
+	r         io.Reader
+	ctr       *ctr
+	mac       hash.Hash
+	remaining int64
+	err       error
+}
+
+// NewReader reads the salt and the password verifier of size bytes of
+// encrypted data, failing with ErrPassword when the verifier does not match.
+// The returned reader decrypts the data and checks the authentication code
+// at its end, failing with ErrAuthentication if it was tampered with.
+func NewReader(r io.Reader, password []byte, strength uint8, size int64) (io.Reader, error) {
+	keySize := keySize(strength)
+	if keySize == 0 {
+		return nil, ErrStrength
+	}
+	if size < int64(Overhead(strength)) {
+		return nil, errTruncated
+	}
+
+	header := make([]byte, keySize/2+verifierSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	block, mac, verifier, err := deriveKeys(password, header[:keySize/2], keySize)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(verifier, header[keySize/2:]) {
+		return nil, ErrPassword
+	}
+
+	return &reader{
+		r:         r,
+		ctr:       newCTR(block),
+		mac:       mac,
+		remaining: size - int64(Overhead(strength)),
+	}, nil
+}
+
+func (r *reader) Read(b []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if r.remaining > 0 {
+		if int64(len(b)) > r.remaining {
+			b = b[:r.remaining]
+		}
+		n, err = r.r.Read(b)
+		r.mac.Write(b[:n])
+		r.ctr.XORKeyStream(b[:n], b[:n])
+		r.remaining -= int64(n)
+		if errors.Is(err, io.EOF) && r.remaining > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil || r.remaining > 0 {
+			r.err = err
+			return
+		}
+	}
+
+	authCode := make([]byte, authCodeSize)
+	if _, err = io.ReadFull(r.r, authCode); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+	} else if !hmac.Equal(authCode, r.mac.Sum(nil)[:authCodeSize]) {
+		err = ErrAuthentication
+	} else {
+		err = io.EOF
+	}
+	r.err = err
+	// the last bytes are handed out before the verdict
+	if n > 0 && err == io.EOF {
+		return n, nil
+	}
+	return n, err
+}
@@ -0,0 +1,516 @@
+package zipfile
+
+import (
+	"compress/flate"
+	"errors"
+	"go-zipfile/serial"
+	"go-zipfile/zipfile/extrafield"
+	"go-zipfile/zipfile/winzipaes"
+	"go-zipfile/zipfile/zipcrypto"
+	"io"
+	"runtime"
+)
+
+var (
+	errWriterClosed = errors.New("zip: writer closed")
+	errEntryClosed  = errors.New("zip: write to closed entry")
+	errDirectory    = errors.New("zip: write to directory entry")
+	errLongName     = errors.New("zip: file name too long")
+)
+
+// compressionFlags returns the general purpose bits that describe how a
+// compression method is applied.
+func compressionFlags(method uint16) uint16 {
+	if method == CompressionMethodLZMA {
+		// the stream ends with an end of stream marker
+		return CompressionOption1
+	}
+	return 0
+}
+
+func (h *FileHeader) minimumVersion() uint16 {
+	switch h.CompressionMethod {
+	case CompressionMethodAEx:
+		// on top of what the method of the encrypted data needs
+		field, err := readAESExtraField(h.ExtraField)
+		if err != nil {
+			return 51
+		}
+		inner := *h
+		inner.CompressionMethod = field.CompressionMethod
+		return max(51, inner.minimumVersion())
+	case CompressionMethodBZIP2:
+		return 46
+	case CompressionMethodLZMA, CompressionMethodZSTD, CompressionMethodXZ, CompressionMethodPPMd:
+		return 63
+	}
+	if h.IsDir() || h.CompressionMethod == CompressionMethodDeflated || h.Flags&EncryptedFlag != 0 {
+		return 20
+	}
+	return DefaultVersion
+}
+
+// localFileHeader builds the local header of the entry, with a ZIP64 record
+// holding both sizes if requested.
+func (h *FileHeader) localFileHeader(zip64 bool) LocalFileHeader {
+	LastModFileDate, LastModFileTime := convertTime(h.Modified)
+	lfh := LocalFileHeader{
+		Signature:         LocalFileHeaderSignature,
+		Version:           h.VersionNeeded,
+		Flags:             h.Flags,
+		CompressionMethod: h.CompressionMethod,
+		LastModFileTime:   LastModFileTime,
+		LastModFileDate:   LastModFileDate,
+		CRC32:             h.CRC32,
+		CompressedSize:    uint32(h.CompressedSize),
+		UncompressedSize:  uint32(h.UncompressedSize),
+		FileNameLength:    uint16(len(h.Name)),
+		ExtraFieldLength:  0,
+		FileName:          []byte(h.Name),
+		ExtraField:        h.ExtraField,
+	}
+
+	if zip64 {
+		lfh.Version = max(lfh.Version, Zip64Version)
+		lfh.CompressedSize = uint32max
+		lfh.UncompressedSize = uint32max
+		lfh.ExtraField = appendZip64ExtraField(h.ExtraField, h.UncompressedSize, h.CompressedSize)
+	}
+	lfh.ExtraFieldLength = uint16(len(lfh.ExtraField))
+
+	return lfh
+}
+
+// centralDirectoryFileHeader builds the central header of the entry, moving
+// the sizes and offset that do not fit 32 bits into a ZIP64 record.
+func (h *FileHeader) centralDirectoryFileHeader(offset uint64) CentralDirectoryFileHeader {
+	LastModFileDate, LastModFileTime := convertTime(h.Modified)
+	cdh := CentralDirectoryFileHeader{
+		Signature:              CentralFileHeaderSignature,
+		Version:                h.Version,
+		VersionNeeded:          h.VersionNeeded,
+		Flags:                  h.Flags,
+		CompressionMethod:      h.CompressionMethod,
+		LastModFileTime:        LastModFileTime,
+		LastModFileDate:        LastModFileDate,
+		CRC32:                  h.CRC32,
+		CompressedSize:         uint32(h.CompressedSize),
+		UncompressedSize:       uint32(h.UncompressedSize),
+		FileNameLength:         uint16(len(h.Name)),
+		ExtraFieldLength:       0,
+		FileCommentLength:      uint16(len(h.Comment)),
+		DiskNumberStart:        0,
+		InternalFileAttributes: h.InternalFileAttributes,
+		ExternalFileAttributes: h.ExternalFileAttributes,
+		OffsetOfLocalHeader:    uint32(offset),
+		FileName:               []byte(h.Name),
+		ExtraField:             centralExtraField(h.ExtraField),
+		FileComment:            []byte(h.Comment),
+	}
+
+	var values []uint64
+	if h.UncompressedSize >= uint32max {
+		cdh.UncompressedSize = uint32max
+		values = append(values, h.UncompressedSize)
+	}
+	if h.CompressedSize >= uint32max {
+		cdh.CompressedSize = uint32max
+		values = append(values, h.CompressedSize)
+	}
+	if offset >= uint32max {
+		cdh.OffsetOfLocalHeader = uint32max
+		values = append(values, offset)
+	}
+	if len(values) > 0 {
+		cdh.VersionNeeded = max(cdh.VersionNeeded, Zip64Version)
+		cdh.ExtraField = appendZip64ExtraField(cdh.ExtraField, values...)
+	}
+	cdh.ExtraFieldLength = uint16(len(cdh.ExtraField))
+
+	return cdh
+}
+
+// centralExtraField returns the extra field block of the central header, whose
+// extended timestamp only holds the modification time.
+func centralExtraField(extra []byte) []byte {
+	var field extrafield.ExtendedTimestampExtraField
+	if !findExtraField(extra, &field) || field.Central {
+		return extra
+	}
+	fields, err := extrafield.Parse(extra)
+	if err != nil {
+		return extra
+	}
+	field.Central = true
+	central, err := extrafield.Serialize(fields.Set(&field))
+	if err != nil {
+		return extra
+	}
+	return central
+}
+
+// writeCentralDirectory writes the central directory found at the given
+// offset, followed by the ZIP64 end of central directory record and locator
+// when the entry count, size or offset overflow the classic record.
+func writeCentralDirectory(writer io.Writer, headers []CentralDirectoryFileHeader, offset uint64, comment string) (err error) {
+	var cdhSize uint64
+	for _, cdh := range headers {
+		if err = serial.Marshal(writer, cdh); err != nil {
+			return
+		}
+		cdhSize += uint64(cdh.SizeOf())
+	}
+
+	TotalEntries := uint64(len(headers))
+	if TotalEntries >= uint16max || cdhSize >= uint32max || offset >= uint32max {
+		z64eocdr := Zip64EndOfCentralDirectoryRecord{
+			Signature:                  Zip64EndOfCentralDirectorySignature,
+			Version:                    LatestVersion,
+			VersionNeeded:              Zip64Version,
+			DiskNumber:                 0,
+			StartingDiskNumber:         0,
+			DiskTotalEntries:           TotalEntries,
+			TotalEntries:               TotalEntries,
+			CentralDirectorySize:       cdhSize,
+			OffsetOfStartingDiskNumber: offset,
+		}
+		// the record size leaves out the signature and the size field itself
+		z64eocdr.RecordSize = uint64(z64eocdr.SizeOf()) - 12
+		if err = serial.Marshal(writer, z64eocdr); err != nil {
+			return
+		}
+
+		if err = serial.Marshal(writer, Zip64EndOfCentralDirectoryLocator{
+			Signature:                                Zip64EndOfCentralDirectoryLocatorSignature,
+			StartingDiskNumber:                       0,
+			OffsetOfZip64EndOfCentralDirectoryRecord: offset + cdhSize,
+			TotalDisks:                               1,
+		}); err != nil {
+			return
+		}
+	}
+
+	return serial.Marshal(writer, EndOfCentralDirectoryRecord{
+		Signature:                  EndOfCentralDirectorySignature,
+		DiskNumber:                 0,
+		StartingDiskNumber:         0,
+		DiskTotalEntries:           uint16(min(TotalEntries, uint16max)),
+		TotalEntries:               uint16(min(TotalEntries, uint16max)),
+		CentralDirectorySize:       uint32(min(cdhSize, uint32max)),
+		OffsetOfStartingDiskNumber: uint32(min(offset, uint32max)),
+		ZIPFileCommentLength:       uint16(len(comment)),
+		ZIPFileComment:             []byte(comment),
+	})
+}
+
+// Writer writes an archive to a plain io.Writer. Since nothing can be patched
+// afterward, the checksum and sizes of each entry follow its data in a data
+// descriptor, as announced by DataDescriptorFlag.
+type Writer struct {
+	w                       *countWriter
+	headers                 []CentralDirectoryFileHeader
+	current                 *fileWriter
+	closed                  bool
+	Comment                 string
+	CompressionLevel        int
+	EncoderConcurrency      int
+	DataDescriptorSignature bool
+	Password                string
+	Encryption              uint8
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:                       &countWriter{w: w},
+		CompressionLevel:        flate.DefaultCompression,
+		EncoderConcurrency:      1,
+		DataDescriptorSignature: true,
+	}
+}
+
+// SetComment sets the archive comment. It has no flag to tell it is UTF-8, so
+// readers take text beyond ASCII for CP437 unless told otherwise.
+func (w *Writer) SetComment(comment string) {
+	w.Comment = comment
+}
+
+func (w *Writer) SetDeflateLevel(level int) {
+	w.CompressionLevel = level
+}
+
+// SetCompressionLevel sets the level handed to the compressor of any method.
+func (w *Writer) SetCompressionLevel(level int) {
+	w.CompressionLevel = level
+}
+
+// SetEncoderConcurrency sets how many goroutines encode each entry, one by
+// default, or all processors for n <= 0, for the methods whose encoder can
+// use several, such as Zstandard.
+func (w *Writer) SetEncoderConcurrency(n int) {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	w.EncoderConcurrency = n
+}
+
+// SetDataDescriptorSignature chooses whether data descriptors start with their
+// optional signature. APPNOTE recommends it, some old readers do not expect it.
+func (w *Writer) SetDataDescriptorSignature(enabled bool) {
+	w.DataDescriptorSignature = enabled
+}
+
+// SetPassword encrypts the entries created from now on with the method chosen
+// by SetEncryption, or stops encrypting them if the password is empty.
+func (w *Writer) SetPassword(password string) {
+	w.Password = password
+}
+
+// SetEncryption chooses how entries are encrypted when a password is set,
+// EncryptionZipCrypto by default. Since the size of an entry is unknown while
+// its local header is written, AES entries use AE-2 and leave their CRC-32 out,
+// unless the UncompressedSize passed to Create announces a larger entry.
+func (w *Writer) SetEncryption(method uint8) {
+	w.Encryption = method
+}
+
+// Create writes the local header of a new entry and returns a writer for its
+// uncompressed data, valid until the next call to Create or Close. The
+// UncompressedSize of the header is only a hint: when it is 0, unknown, or
+// close enough to 4 GiB for the compressed data to reach it, the local header
+// holds a ZIP64 extra field announcing ZIP64 sizes in the data descriptor.
+func (w *Writer) Create(header *FileHeader) (_ io.Writer, err error) {
+	if w.closed {
+		return nil, errWriterClosed
+	}
+	if err = w.closeEntry(); err != nil {
+		return
+	}
+	if len(header.Name) > 0xffff || len(header.Comment) > 0xffff || len(header.ExtraField) > 0xffff {
+		return nil, errLongName
+	}
+
+	fh := *header
+	fh.Flags |= fh.encodingFlags()
+	size := fh.UncompressedSize
+	// APPNOTE only allows ZIP64 sizes in the data descriptor of an entry whose
+	// local header has a ZIP64 extra field, which cannot be added afterwards
+	zip64 := size == 0 || mayNeedZip64(size)
+	fh.CRC32 = 0
+	fh.CompressedSize = 0
+	fh.UncompressedSize = 0
+	if fh.Version == 0 {
+		fh.Version = LatestVersion
+	}
+	if fh.VersionNeeded == 0 {
+		fh.VersionNeeded = fh.minimumVersion()
+	}
+
+	offset := uint64(w.w.count)
+
+	if fh.IsDir() {
+		fh.CompressionMethod = CompressionMethodStored
+		fh.Flags &^= DataDescriptorFlag | EncryptedFlag
+		if err = serial.Marshal(w.w, fh.localFileHeader(false)); err != nil {
+			return
+		}
+		w.headers = append(w.headers, fh.centralDirectoryFileHeader(offset))
+		return directoryWriter{}, nil
+	}
+
+	if _, err = compressor(fh.CompressionMethod); err != nil {
+		return
+	}
+	fh.Flags |= compressionFlags(fh.CompressionMethod) | DataDescriptorFlag
+	if len(w.Password) > 0 {
+		if err = fh.encrypt(w.Encryption, size); err != nil {
+			return
+		}
+		fh.VersionNeeded = max(fh.VersionNeeded, fh.minimumVersion())
+	}
+	if zip64 {
+		fh.VersionNeeded = max(fh.VersionNeeded, Zip64Version)
+	}
+	if err = serial.Marshal(w.w, fh.localFileHeader(zip64)); err != nil {
+		return
+	}
+
+	options := CompressorOptions{Level: w.CompressionLevel, Concurrency: w.EncoderConcurrency}
+	fw, err := newFileWriter(w.w, &fh, options, []byte(w.Password))
+	if err != nil {
+		return
+	}
+	fw.offset = offset
+	fw.zip64 = zip64
+	w.current = fw
+	return fw, nil
+}
+
+func (w *Writer) closeEntry() (err error) {
+	fw := w.current
+	if fw == nil {
+		return
+	}
+	w.current = nil
+
+	if err = fw.close(); err != nil {
+		return
+	}
+	if err = writeDataDescriptor(w.w, fw.header, fw.zip64, w.DataDescriptorSignature); err != nil {
+		return
+	}
+
+	w.headers = append(w.headers, fw.header.centralDirectoryFileHeader(fw.offset))
+	return
+}
+
+// Close finishes the last entry and writes the central directory. The
+// underlying writer is not closed.
+func (w *Writer) Close() (err error) {
+	if w.closed {
+		return errWriterClosed
+	}
+	if err = w.closeEntry(); err != nil {
+		return
+	}
+	w.closed = true
+	return writeCentralDirectory(w.w, w.headers, uint64(w.w.count), w.Comment)
+}
+
+func writeDataDescriptor(writer io.Writer, fh *FileHeader, zip64, signature bool) (err error) {
+	if signature {
+		if err = serial.Marshal(writer, DataDescriptorSignature); err != nil {
+			return
+		}
+	}
+	if zip64 || fh.isZip64() {
+		return serial.Marshal(writer, Zip64DataDescriptor{
+			CRC32:            fh.CRC32,
+			CompressedSize:   fh.CompressedSize,
+			UncompressedSize: fh.UncompressedSize,
+		})
+	}
+	return serial.Marshal(writer, DataDescriptor{
+		CRC32:            fh.CRC32,
+		CompressedSize:   uint32(fh.CompressedSize),
+		UncompressedSize: uint32(fh.UncompressedSize),
+	})
+}
+
+// fileWriter takes the uncompressed data of an entry and passes it through the
+// compressor, the encryption if any, and a counter of the bytes stored.
+type fileWriter struct {
+	header     *FileHeader
+	offset     uint64
+	zip64      bool
+	method     uint16
+	dst        io.Writer
+	payload    *countWriter
+	compressor io.WriteCloser
+	encryptor  io.WriteCloser
+	compressed *countWriter
+	checksum   checksumWriter
+	omitCRC    bool
+	size       int64
+	closed     bool
+}
+
+func newFileWriter(w io.Writer, fh *FileHeader, options CompressorOptions, password []byte) (fw *fileWriter, err error) {
+	if fw, err = newRawFileWriter(w, fh, password); err != nil {
+		return
+	}
+
+	compress, err := compressor(fw.method)
+	if err != nil {
+		return
+	}
+	if fw.compressor, err = compress(fw.dst, options); err != nil {
+		return
+	}
+	return
+}
+
+// newRawFileWriter returns a fileWriter that only encrypts, for data that is
+// already compressed with fw.method and handed to writeCompressed.
+func newRawFileWriter(w io.Writer, fh *FileHeader, password []byte) (fw *fileWriter, err error) {
+	fw = &fileWriter{header: fh, compressed: &countWriter{w: w}, method: fh.CompressionMethod}
+
+	fw.dst = fw.compressed
+	if fh.CompressionMethod == CompressionMethodAEx {
+		var field extrafield.AESExtraField
+		if field, err = readAESExtraField(fh.ExtraField); err != nil {
+			return
+		}
+		if fw.encryptor, err = winzipaes.NewWriter(fw.dst, password, field.Strength); err != nil {
+			return
+		}
+		fw.dst = fw.encryptor
+		fw.method = field.CompressionMethod
+		fw.omitCRC = field.Version == extrafield.AESVersion2
+	} else if fh.Flags&EncryptedFlag != 0 {
+		// the CRC-32 is not known yet, so the check byte comes from the time
+		// as allowed with data descriptors
+		_, LastModFileTime := convertTime(fh.Modified)
+		if fw.dst, err = zipcrypto.NewWriter(fw.dst, password, byte(LastModFileTime.Get()>>8)); err != nil {
+			return
+		}
+	}
+
+	// the compressed data is counted before encryption
+	fw.payload = &countWriter{w: fw.dst}
+	fw.dst = fw.payload
+	fw.compressor = nopWriteCloser{fw.dst}
+	return
+}
+
+// writeCompressed stores data compressed elsewhere along with the data it was
+// compressed from, which the checksum and size are taken from.
+func (fw *fileWriter) writeCompressed(compressed, uncompressed []byte) (err error) {
+	if fw.closed {
+		return errEntryClosed
+	}
+	if _, err = fw.dst.Write(compressed); err != nil {
+		return
+	}
+	_, _ = fw.checksum.Write(uncompressed)
+	fw.size += int64(len(uncompressed))
+	return
+}
+
+func (fw *fileWriter) Write(b []byte) (n int, err error) {
+	if fw.closed {
+		return 0, errEntryClosed
+	}
+	n, err = fw.compressor.Write(b)
+	_, _ = fw.checksum.Write(b[:n])
+	fw.size += int64(n)
+	return
+}
+
+// close flushes the compressor and completes the header with the checksum and sizes.
+func (fw *fileWriter) close() (err error) {
+	fw.closed = true
+	if err = fw.compressor.Close(); err != nil {
+		return
+	}
+	if fw.encryptor != nil {
+		if err = fw.encryptor.Close(); err != nil {
+			return
+		}
+	}
+	fw.header.CRC32 = fw.checksum.crc
+	if fw.omitCRC {
+		fw.header.CRC32 = 0
+	}
+	fw.header.CompressedSize = uint64(fw.compressed.count)
+	fw.header.UncompressedSize = uint64(fw.size)
+	return
+}
+
+type directoryWriter struct{}
+
+func (directoryWriter) Write(b []byte) (int, error) {
+	if len(b) > 0 {
+		return 0, errDirectory
+	}
+	return 0, nil
+}
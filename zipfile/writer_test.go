@@ -0,0 +1,95 @@
+package zipfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// descriptor returns the data descriptor following the data of an entry,
+// without its signature, and whether it has one.
+func descriptor(t *testing.T, archive []byte, e *Entry, size int) (fields []byte, signature bool) {
+	t.Helper()
+	offset, err := e.findDataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = archive[offset+int64(e.CompressedSize):]
+	if signature = bytes.HasPrefix(fields, DataDescriptorSignature[:]); signature {
+		fields = fields[len(DataDescriptorSignature):]
+	}
+	return fields[:size], signature
+}
+
+func TestWriterDataDescriptor(t *testing.T) {
+	content := bytes.Repeat([]byte("text "), 1000)
+	tests := []struct {
+		name      string
+		method    uint16
+		signature bool
+		hint      uint64
+	}{
+		{"stored", CompressionMethodStored, true, 0},
+		{"deflated", CompressionMethodDeflated, true, 0},
+		{"no signature", CompressionMethodDeflated, false, 0},
+		// a known size below 4 GiB keeps the sizes of the descriptor on 32 bits
+		{"size hint", CompressionMethodDeflated, true, uint64(len(content))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := NewWriter(&buf)
+			zw.SetDataDescriptorSignature(test.signature)
+			for _, name := range []string{"a.txt", "b.txt"} {
+				w, err := zw.Create(&FileHeader{Name: name, CompressionMethod: test.method, UncompressedSize: test.hint})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err = w.Write(content); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			archive := buf.Bytes()
+			zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range zr.Entries {
+				if e.Flags&DataDescriptorFlag == 0 {
+					t.Errorf("%s: flags %#x without a data descriptor", e.Name, e.Flags)
+				}
+
+				// entries of unknown size get ZIP64 sizes in their descriptor
+				zip64 := test.hint == 0
+				size := 12
+				if zip64 {
+					size = 20
+				}
+				fields, signature := descriptor(t, archive, e, size)
+				if signature != test.signature {
+					t.Errorf("%s: signature %t, want %t", e.Name, signature, test.signature)
+				}
+				crc := binary.LittleEndian.Uint32(fields)
+				compressedSize := uint64(binary.LittleEndian.Uint32(fields[4:]))
+				uncompressedSize := uint64(binary.LittleEndian.Uint32(fields[8:]))
+				if zip64 {
+					compressedSize = binary.LittleEndian.Uint64(fields[4:])
+					uncompressedSize = binary.LittleEndian.Uint64(fields[12:])
+				}
+				if crc != e.CRC32 || compressedSize != e.CompressedSize || uncompressedSize != e.UncompressedSize {
+					t.Errorf("%s: descriptor %08x %d %d, want %08x %d %d", e.Name,
+						crc, compressedSize, uncompressedSize, e.CRC32, e.CompressedSize, e.UncompressedSize)
+				}
+			}
+
+			contents := readAll(t, archive, "")
+			if contents["a.txt"] != string(content) || contents["b.txt"] != string(content) {
+				t.Errorf("contents differ from what was written")
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package zipfile
+
+import (
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+func init() {
+	RegisterCompressor(CompressionMethodXZ, newXZWriter)
+	RegisterDecompressor(CompressionMethodXZ, newXZReader)
+}
+
+// newXZWriter takes the levels of the xz tool like LZMA. The stream is split in
+// blocks of three times the dictionary size, as xz does when multi-threaded,
+// each with a CRC-64.
+func newXZWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	dictCap := lzmaDictCap(level)
+	xw, err := xz.WriterConfig{
+		DictCap:   dictCap,
+		BlockSize: 3 * int64(dictCap),
+		CheckSum:  xz.CRC64,
+	}.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return xw, nil
+}
+
+// newXZReader reads any number of concatenated streams and blocks, verifying
+// the integrity check of each block and the index of each stream.
+func newXZReader(r io.Reader) io.ReadCloser {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return errorReadCloser{err}
+	}
+	return io.NopCloser(xr)
+}
@@ -0,0 +1,55 @@
+package zipfile
+
+import (
+	"encoding/binary"
+	"go-zipfile/zipfile/extrafield"
+)
+
+const (
+	uint16max = 0xffff
+	uint32max = 0xffffffff
+)
+
+func (h *FileHeader) isZip64() bool {
+	return h.CompressedSize >= uint32max || h.UncompressedSize >= uint32max
+}
+
+// mayNeedZip64 tells whether data of the given size could reach 4 GiB once
+// compressed. Deflate grows incompressible data by less than 1/128.
+func mayNeedZip64(size uint64) bool {
+	return size+size/128+1024 >= uint32max
+}
+
+// appendZip64ExtraField replaces any ZIP64 record of the extra field block by
+// one holding the given values.
+func appendZip64ExtraField(extra []byte, values ...uint64) []byte {
+	extra = extrafield.Remove(extra, extrafield.Zip64TagType)
+	if len(values) == 0 {
+		return extra
+	}
+
+	record, _ := (&extrafield.Zip64ExtraField{Values: values}).MarshalBinary()
+	return append(extra, record...)
+}
+
+// readZip64ExtraField replaces the fields holding 0xffffffff by the values of
+// the ZIP64 record of the extra field block.
+func readZip64ExtraField(extra []byte, fields ...*uint64) error {
+	record := extrafield.Find(extra, extrafield.Zip64TagType)
+	if record == nil {
+		return nil
+	}
+
+	data := record[4:]
+	for _, field := range fields {
+		if *field != uint32max {
+			continue
+		}
+		if len(data) < 8 {
+			return ErrFormat
+		}
+		*field = binary.LittleEndian.Uint64(data)
+		data = data[8:]
+	}
+	return nil
+}
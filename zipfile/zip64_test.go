@@ -0,0 +1,128 @@
+package zipfile
+
+import (
+	"bytes"
+	"testing"
+
+	"go-zipfile/serial"
+	"go-zipfile/zipfile/extrafield"
+)
+
+func TestZip64EntryCount(t *testing.T) {
+	tests := []struct {
+		count int
+		zip64 bool
+	}{
+		{uint16max - 1, false},
+		// 0xffff in the classic record tells to look for the ZIP64 one
+		{uint16max, true},
+		{70000, true},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		zw := NewWriter(&buf)
+		for range test.count {
+			if _, err := zw.Create(&FileHeader{Name: "a", UncompressedSize: 1}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r := bytes.NewReader(buf.Bytes())
+		eocdr, offset, err := findEndOfCentralDirectory(r, r.Size())
+		if err != nil {
+			t.Fatal(err)
+		}
+		z64eocdr, _, err := findZip64EndOfCentralDirectory(r, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (z64eocdr != nil) != test.zip64 || eocdr.TotalEntries != uint16(min(test.count, uint16max)) {
+			t.Errorf("%d entries: ZIP64 record %t, classic count %d", test.count, z64eocdr != nil, eocdr.TotalEntries)
+		}
+
+		zr, err := NewReader(r, r.Size())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(zr.Entries) != test.count {
+			t.Errorf("%d entries read back, want %d", len(zr.Entries), test.count)
+		}
+	}
+}
+
+func TestZip64CentralHeader(t *testing.T) {
+	tests := []struct {
+		name                     string
+		uncompressed, compressed uint64
+		offset                   uint64
+		zip64                    bool
+	}{
+		{"small", 1000, 500, 1 << 20, false},
+		{"uncompressed size", uint32max, 1000, 0, true},
+		{"sizes", 5 << 30, 5<<30 + 100, 0, true},
+		{"offset", 1000, 500, 5 << 30, true},
+		{"all", 6 << 30, 5 << 30, 7 << 30, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fh := FileHeader{Name: "a", VersionNeeded: 20, UncompressedSize: test.uncompressed, CompressedSize: test.compressed}
+			cdh := fh.centralDirectoryFileHeader(test.offset)
+			zip64 := extrafield.Find(cdh.ExtraField, extrafield.Zip64TagType) != nil
+			if zip64 != test.zip64 || (cdh.VersionNeeded >= Zip64Version) != test.zip64 {
+				t.Errorf("ZIP64 record %t, version needed %d", zip64, cdh.VersionNeeded)
+			}
+
+			e, err := newEntry(&Reader{size: 8 << 30}, &cdh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.UncompressedSize != test.uncompressed || e.CompressedSize != test.compressed || uint64(e.offsetOfLocalHeader) != test.offset {
+				t.Errorf("read back sizes %d %d offset %d", e.UncompressedSize, e.CompressedSize, e.offsetOfLocalHeader)
+			}
+		})
+	}
+}
+
+func TestWriterZip64LocalHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		hint  uint64
+		zip64 bool
+	}{
+		{"unknown size", 0, true},
+		{"small", 100, false},
+		{"near 4 GiB", uint32max - 100, true},
+		{"large", 5 << 30, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := NewWriter(&buf)
+			w, err := zw.Create(&FileHeader{Name: "a.txt", CompressionMethod: CompressionMethodDeflated, UncompressedSize: test.hint})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = w.Write([]byte("hello, world")); err != nil {
+				t.Fatal(err)
+			}
+			if err = zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var lfh LocalFileHeader
+			if err = serial.Unmarshal(bytes.NewReader(buf.Bytes()), &lfh); err != nil {
+				t.Fatal(err)
+			}
+			zip64 := extrafield.Find(lfh.ExtraField, extrafield.Zip64TagType) != nil
+			if zip64 != test.zip64 || (lfh.Version >= Zip64Version) != test.zip64 {
+				t.Errorf("ZIP64 record %t, version needed %d", zip64, lfh.Version)
+			}
+			if contents := readAll(t, buf.Bytes(), ""); contents["a.txt"] != "hello, world" {
+				t.Errorf("contents = %q", contents)
+			}
+		})
+	}
+}
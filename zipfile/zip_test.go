@@ -0,0 +1,102 @@
+package zipfile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-zipfile/serial"
+)
+
+// addFiles adds files of the given names and contents, in pairs, to the archive.
+func addFiles(t *testing.T, z *Zip, files ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	for i := 0; i < len(files); i += 2 {
+		path := filepath.Join(dir, files[i])
+		if err := os.WriteFile(path, []byte(files[i+1]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := z.Add(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readAll returns the content of the entries of an archive by name.
+func readAll(t *testing.T, archive []byte, password string) map[string]string {
+	t.Helper()
+	zr, err := NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr.SetPassword(password)
+
+	contents := make(map[string]string)
+	for _, e := range zr.Entries {
+		rc, err := e.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", e.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", e.Name, err)
+		}
+		contents[filepath.Base(e.Name)] = string(data)
+	}
+	return contents
+}
+
+func TestBuild(t *testing.T) {
+	text := string(bytes.Repeat([]byte("compressible text "), 100))
+	tests := []struct {
+		name     string
+		method   uint16
+		password string
+	}{
+		{"stored", CompressionMethodStored, ""},
+		{"deflated", CompressionMethodDeflated, ""},
+		{"encrypted", CompressionMethodDeflated, "secret"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			z := NewZip()
+			z.SetCompressionMethod(test.method)
+			z.SetPassword(test.password)
+			addFiles(t, z, "a.txt", text, "b.txt", "short")
+
+			ff, err := z.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err = serial.Marshal(&buf, ff); err != nil {
+				t.Fatal(err)
+			}
+
+			contents := readAll(t, buf.Bytes(), test.password)
+			if contents["a.txt"] != text || contents["b.txt"] != "short" {
+				t.Errorf("contents = %q", contents)
+			}
+		})
+	}
+}
+
+func TestFileDataSizeOf(t *testing.T) {
+	for _, test := range []struct {
+		length int64
+		want   uint32
+	}{
+		{0, 0},
+		{uint32max - 1, uint32max - 1},
+		{uint32max, uint32max},
+		{1 << 40, uint32max},
+	} {
+		if got := NewFileData("", 0, test.length).SizeOf(); got != test.want {
+			t.Errorf("SizeOf(%d) = %#x, want %#x", test.length, got, test.want)
+		}
+	}
+}
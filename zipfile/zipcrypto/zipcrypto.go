@@ -0,0 +1,108 @@
+// Package zipcrypto implements the traditional PKWARE encryption, described in
+// section 6.1 of APPNOTE. It is weak and only meant for compatibility.
+package zipcrypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"go-zipfile/crc"
+	"io"
+)
+
+const HeaderSize = 12
+
+var ErrPassword = errors.New("zip: invalid password")
+
+var crc32 = crc.NewCRC32()
+
+type keys [3]uint32
+
+func newKeys(password []byte) *keys {
+	k := &keys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range password {
+		k.update(b)
+	}
+	return k
+}
+
+func (k *keys) update(b byte) {
+	k[0] = crc32.UpdateByte(k[0], b)
+	k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+	k[2] = crc32.UpdateByte(k[2], byte(k[1]>>24))
+}
+
+func (k *keys) streamByte() byte {
+	temp := uint16(k[2] | 2)
+	return byte((temp * (temp ^ 1)) >> 8)
+}
+
+func (k *keys) encrypt(b byte) byte {
+	c := b ^ k.streamByte()
+	k.update(b)
+	return c
+}
+
+func (k *keys) decrypt(c byte) byte {
+	b := c ^ k.streamByte()
+	k.update(b)
+	return b
+}
+
+type writer struct {
+	w    io.Writer
+	keys *keys
+	buf  []byte
+}
+
+// NewWriter writes the encryption header, whose last byte is the check byte,
+// then encrypts everything written to the returned writer. The check byte is
+// the high byte of the CRC-32, or of the DOS time when a data descriptor follows.
+func NewWriter(w io.Writer, password []byte, check byte) (io.Writer, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := rand.Read(header[:HeaderSize-1]); err != nil {
+		return nil, err
+	}
+	header[HeaderSize-1] = check
+
+	zw := &writer{w: w, keys: newKeys(password)}
+	if _, err := zw.Write(header); err != nil {
+		return nil, err
+	}
+	return zw, nil
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	w.buf = w.buf[:0]
+	for _, c := range b {
+		w.buf = append(w.buf, w.keys.encrypt(c))
+	}
+	return w.w.Write(w.buf)
+}
+
+type reader struct {
+	r    io.Reader
+	keys *keys
+}
+
+// NewReader reads and decrypts the encryption header, failing with ErrPassword
+// when its last byte differs from the check byte.
+func NewReader(r io.Reader, password []byte, check byte) (io.Reader, error) {
+	zr := &reader{r: r, keys: newKeys(password)}
+
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(zr, header); err != nil {
+		return nil, err
+	}
+	if header[HeaderSize-1] != check {
+		return nil, ErrPassword
+	}
+	return zr, nil
+}
+
+func (r *reader) Read(b []byte) (n int, err error) {
+	n, err = r.r.Read(b)
+	for i := range b[:n] {
+		b[i] = r.keys.decrypt(b[i])
+	}
+	return
+}
@@ -0,0 +1,46 @@
+package zipfile
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	RegisterOptionsCompressor(CompressionMethodZSTD, newZstdWriter)
+	RegisterDecompressor(CompressionMethodZSTD, newZstdReader)
+	// early writers used the method now marked deprecated for the same format
+	RegisterDecompressor(CompressionMethodDeprecatedZSTD, newZstdReader)
+}
+
+// newZstdWriter takes the levels of the zstd tool, 1 to 22, which the encoder
+// rounds to its fastest, default, better and best speeds. Other levels,
+// such as the default of deflate, select level 3. More than one goroutine only
+// pays off for entries of several megabytes.
+func newZstdWriter(w io.Writer, options CompressorOptions) (io.WriteCloser, error) {
+	level := options.Level
+	if level < 1 || level > 22 {
+		level = 3
+	}
+	return zstd.NewWriter(w,
+		zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+		zstd.WithEncoderConcurrency(max(options.Concurrency, 1)),
+	)
+}
+
+func newZstdReader(r io.Reader) io.ReadCloser {
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return errorReadCloser{err}
+	}
+	return zstdReader{decoder}
+}
+
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (r zstdReader) Close() error {
+	r.Decoder.Close()
+	return nil
+}